@@ -2,42 +2,185 @@ package app
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
 
 	"github.com/nurkenspashev92/emob/cmd/router"
 	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/events"
+	"github.com/nurkenspashev92/emob/internal/grpcapi"
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/idempotency"
+	"github.com/nurkenspashev92/emob/internal/jobs"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/metrics"
+	"github.com/nurkenspashev92/emob/internal/stream"
+	"github.com/nurkenspashev92/emob/internal/tlsconfig"
+	"github.com/nurkenspashev92/emob/internal/tracing"
 	"github.com/nurkenspashev92/emob/pkg/store"
 )
 
 type App struct {
-	fiberApp *fiber.App
+	fiberApp   *fiber.App
+	grpcServer *grpc.Server
+	changes    *stream.Hub
+	health     *health.Registry
+
+	shutdownDelay time.Duration
 }
 
-func (a *App) Run() {
-	cfg := configs.NewConfig()
+// Run loads the configuration from args and the environment and serves
+// until SIGINT or SIGTERM.
+func (a *App) Run(args []string) {
+	cfg, err := configs.Load("emob", args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		cfg.PrintSettings(os.Stdout)
+		return
+	}
+
+	logger, err := logging.New(cfg.Log, os.Stdout)
+	if err != nil {
+		log.Fatalf("Failed to initialize logging: %v", err)
+	}
+	slog.SetDefault(logger)
+
+	for _, s := range cfg.Settings() {
+		slog.Debug("Config", "key", s.Key, "value", s.Value, "source", s.Source)
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Failed to initialize tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("Failed to flush traces", "error", err)
+		}
+	}()
 
-	database, err := store.NewPostgresDb(cfg)
+	// Ctrl+C still works while startup waits for the database
+	startCtx, stopStart := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	if cfg.Migrate.OnStart {
+		if err := migrateUp(startCtx, cfg); err != nil {
+			fatal("Failed to apply migrations", err)
+		}
+	}
+	database, err := store.NewPostgresDb(startCtx, cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize the database: %v", err)
+		fatal("Failed to initialize the database", err)
 	}
 	defer database.Close()
 
-	a.fiberApp = router.RegisterRoutes(database.Conn)
-	done := make(chan bool, 1)
-	go func() {
-		portStr := os.Getenv("APP_PORT")
-		if portStr == "" {
-			portStr = "8080"
+	// Serving an outdated schema fails request by request, refuse to start
+	err = store.CheckSchema(startCtx, database.Conn)
+	stopStart()
+	if err != nil {
+		fatal("Database schema is not up to date, run emob migrate up or set MIGRATE_ON_START", err)
+	}
+
+	metrics.Registry.MustRegister(metrics.NewPoolCollector(database.Conn))
+
+	a.health = health.NewRegistry(cfg.Health.CheckTimeout)
+	a.health.Register("database", health.Database(database.Conn), health.Readiness, health.Startup)
+	a.health.Register("migrations", health.Migrations(database.Conn), health.Readiness, health.Startup)
+	a.shutdownDelay = cfg.Health.ShutdownDelay
+
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	if cfg.Purge.Enabled {
+		purge := jobs.NewPurgeJob(database.Conn, cfg.Purge)
+		a.health.Register("purge_job", purge.Heartbeat().Check, health.Liveness)
+		go purge.Run(jobsCtx)
+	}
+
+	if cfg.Webhooks.WorkerEnabled {
+		delivery := jobs.NewWebhookDeliveryJob(database.Conn, cfg.Webhooks)
+		a.health.Register("webhook_delivery_job", delivery.Heartbeat().Check, health.Liveness)
+		go delivery.Run(jobsCtx)
+
+		renewals := jobs.NewRenewalNoticeJob(database.Conn, cfg.Webhooks)
+		a.health.Register("renewal_notice_job", renewals.Heartbeat().Check, health.Liveness)
+		go renewals.Run(jobsCtx)
+	}
+
+	if cfg.Outbox.RelayEnabled {
+		relay := jobs.NewOutboxRelayJob(database.Conn, events.NewLogPublisher(os.Stdout), cfg.Outbox)
+		a.health.Register("outbox_relay_job", relay.Heartbeat().Check, health.Liveness)
+		go relay.Run(jobsCtx)
+	}
+
+	var idempotencyStore idempotency.Store = idempotency.NewPostgresStore(database.Conn)
+	if cfg.Idempotency.Store == "memory" {
+		idempotencyStore = idempotency.NewMemoryStore()
+	}
+	cleanup := jobs.NewIdempotencyCleanupJob(idempotencyStore, cfg.Idempotency.CleanupInterval)
+	a.health.Register("idempotency_cleanup_job", cleanup.Heartbeat().Check, health.Liveness)
+	go cleanup.Run(jobsCtx)
+
+	a.changes = stream.NewHub(database.Conn)
+	// The rest of the API works while the listener reconnects
+	a.health.RegisterOptional("change_stream", a.changes.Check, health.Readiness)
+	go a.changes.Run(jobsCtx)
+
+	a.fiberApp = router.RegisterRoutes(cfg, router.Dependencies{
+		DB:          database.Conn,
+		Idempotency: idempotencyStore,
+		Changes:     a.changes,
+		Logger:      logger,
+		Health:      a.health,
+	})
+	if cfg.GRPC.Enabled {
+		listener, err := net.Listen("tcp", "0.0.0.0:"+cfg.GRPC.Port)
+		if err != nil {
+			fatal("Failed to listen for gRPC", err)
 		}
 
-		err := a.fiberApp.Listen("0.0.0.0:" + portStr)
+		a.grpcServer = grpcapi.NewServer(database.Conn, cfg)
+		go func() {
+			if err := a.grpcServer.Serve(listener); err != nil {
+				panic(fmt.Sprintf("grpc server error: %s", err))
+			}
+		}()
+	}
+
+	listener, err := net.Listen("tcp", "0.0.0.0:"+cfg.AppPort)
+	if err != nil {
+		fatal("Failed to listen for HTTP", err)
+	}
+	if cfg.HTTP.TLS.Enabled() {
+		certs, err := tlsconfig.NewReloader(cfg.HTTP.TLS)
+		if err != nil {
+			fatal("Failed to load the TLS certificate", err)
+		}
+		go certs.Run(jobsCtx)
+		listener = tls.NewListener(listener, certs.Config())
+	}
+
+	done := make(chan bool, 1)
+	go func() {
+		err := a.fiberApp.Listener(listener)
 		if err != nil {
 			panic(fmt.Sprintf("http server error: %s", err))
 		}
@@ -45,7 +188,8 @@ func (a *App) Run() {
 
 	go a.Shutdown(done)
 	<-done
-	log.Println("Graceful shutdown complete.")
+	stopJobs()
+	slog.Info("Graceful shutdown complete")
 }
 
 func (fiberServer *App) Shutdown(done chan bool) {
@@ -53,15 +197,55 @@ func (fiberServer *App) Shutdown(done chan bool) {
 	defer stop()
 
 	<-ctx.Done()
-	log.Println("shutting down gracefully, press Ctrl+C again to force")
+	slog.Info("Shutting down gracefully, press Ctrl+C again to force")
+
+	// Fail readiness first so no new traffic is routed here while the
+	// servers drain
+	fiberServer.health.SetShuttingDown()
+	time.Sleep(fiberServer.shutdownDelay)
+
+	// Open event streams never finish on their own
+	fiberServer.changes.Close()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	if err := fiberServer.fiberApp.ShutdownWithContext(ctx); err != nil {
-		log.Printf("Server forced to shutdown with error: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
+	}
+
+	if fiberServer.grpcServer != nil {
+		stopped := make(chan struct{})
+		go func() {
+			fiberServer.grpcServer.GracefulStop()
+			close(stopped)
+		}()
+
+		select {
+		case <-stopped:
+		case <-ctx.Done():
+			slog.Warn("gRPC server forced to stop")
+			fiberServer.grpcServer.Stop()
+		}
 	}
 
-	log.Println("Server exiting")
+	slog.Info("Server exiting")
 
 	done <- true
 }
+
+// migrateUp applies pending migrations before the pool is opened. Instances
+// starting together take turns through the advisory lock.
+func migrateUp(ctx context.Context, cfg *configs.Config) error {
+	migrator, err := store.NewMigrator(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	return migrator.Up()
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
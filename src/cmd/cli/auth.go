@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+)
+
+var authKey = command{
+	group:   "auth",
+	name:    "key",
+	summary: "generate an API key and its AUTH_API_KEYS entry",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		subject := fs.String("subject", "", "user ID, or a name for admin keys (required)")
+		role := fs.String("role", auth.RoleUser, "role: user or admin")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 0); err != nil {
+				return err
+			}
+			switch *role {
+			case auth.RoleUser:
+				if _, err := uuid.Parse(*subject); err != nil {
+					return fmt.Errorf("%w: -subject of a user key must be a user ID", errUsage)
+				}
+			case auth.RoleAdmin:
+				if *subject == "" {
+					return fmt.Errorf("%w: -subject is required", errUsage)
+				}
+			default:
+				return fmt.Errorf("%w: -role must be user or admin", errUsage)
+			}
+
+			// Only the hash goes into the configuration, the key is shown once
+			key, hash, err := auth.NewKey()
+			if err != nil {
+				return err
+			}
+
+			entry := *subject + ":" + *role + ":" + hash
+			return s.out.values(
+				map[string]string{"key": key, "entry": entry},
+				[]string{"key", "entry"},
+				[][]string{{key, entry}},
+			)
+		}
+	},
+}
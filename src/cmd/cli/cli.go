@@ -0,0 +1,225 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"os/user"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/cmd/app"
+	"github.com/nurkenspashev92/emob/cmd/migrate"
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/pkg/store"
+)
+
+// command is a leaf of the command tree such as "subscriptions list", or a
+// top-level command such as "seed" when name is empty. setup defines its
+// flags and returns the function running it.
+type command struct {
+	group   string
+	name    string
+	args    string
+	summary string
+	setup   func(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error
+}
+
+// session is what a command runs against. The database is connected on
+// first use, after the command checked its arguments.
+type session struct {
+	cfg      *configs.Config
+	out      output
+	database *store.Database
+}
+
+func (s *session) db(ctx context.Context) (*pgxpool.Pool, error) {
+	if s.database == nil {
+		database, err := store.NewPostgresDb(ctx, s.cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.database = database
+	}
+	return s.database.Conn, nil
+}
+
+// errUsage makes Run print the usage of the command that failed
+var errUsage = errors.New("invalid arguments")
+
+var commands = []command{
+	subscriptionsList,
+	subscriptionsGet,
+	subscriptionsCreate,
+	subscriptionsDelete,
+	reportTotal,
+	usersSubscriptions,
+	usersCalendarToken,
+	authKey,
+	seedCommand,
+}
+
+const usage = `usage: emob <command> [flags]
+
+commands:
+  serve                       run the HTTP and gRPC servers (default)
+  migrate                     apply or inspect database migrations
+%s
+Run emob <command> -help for the flags of a command. Every command also
+accepts the configuration flags of the app, see emob serve -help.`
+
+// Run dispatches to the command named by the first argument. Without one, or
+// when it is a flag, the servers are started as before commands existed.
+func Run(args []string) {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		new(app.App).Run(args)
+		return
+	}
+
+	switch args[0] {
+	case "serve":
+		new(app.App).Run(args[1:])
+		return
+	case "migrate":
+		migrate.Run(args[1:])
+		return
+	case "help":
+		printUsage(os.Stdout)
+		return
+	}
+
+	group := args[0]
+	if !slices.ContainsFunc(commands, func(c command) bool { return c.group == group }) {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", group)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.group == group && c.name == "" {
+			os.Exit(c.run(args[1:]))
+		}
+	}
+
+	if len(args) > 1 {
+		for _, c := range commands {
+			if c.group == group && c.name == args[1] {
+				os.Exit(c.run(args[2:]))
+			}
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "usage: emob %s <command> [flags]\n\ncommands:\n", group)
+	for _, c := range commands {
+		if c.group == group {
+			fmt.Fprintf(os.Stderr, "  %-26s  %s\n", strings.TrimSpace(c.name+" "+c.args), c.summary)
+		}
+	}
+	os.Exit(2)
+}
+
+func printUsage(w io.Writer) {
+	var b strings.Builder
+	for _, c := range commands {
+		fmt.Fprintf(&b, "  %-26s  %s\n", strings.TrimSpace(c.group+" "+c.name), c.summary)
+	}
+	fmt.Fprintf(w, usage+"\n", b.String())
+}
+
+// run parses the flags and runs the command, returning the exit code
+func (c command) run(args []string) int {
+	title := strings.TrimSpace("emob " + c.group + " " + c.name)
+
+	// The command flags are kept apart so -help lists them without the
+	// configuration flags
+	own := flag.NewFlagSet(title, flag.ContinueOnError)
+	format := own.String("o", formatTable, "output format: table, json or csv")
+	run := c.setup(own)
+
+	commandUsage := func() {
+		fmt.Fprintf(os.Stderr, "usage: %s\n\n%s\n\nflags:\n", strings.TrimSpace(title+" [flags] "+c.args), c.summary)
+		own.SetOutput(os.Stderr)
+		own.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nThe configuration flags of the app are accepted too, see emob serve -help.")
+	}
+
+	fs := flag.NewFlagSet(title, flag.ContinueOnError)
+	fs.Usage = commandUsage
+	own.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+
+	cfg, rest, err := configs.LoadFlags(fs, args)
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	// Mark the flags given as set on own too, so commands can Visit them
+	fs.Visit(func(f *flag.Flag) {
+		if own.Lookup(f.Name) != nil {
+			own.Set(f.Name, f.Value.String())
+		}
+	})
+
+	out, err := newOutput(*format, os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// Logs go to stderr so they never mix with the output
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	ctx = audit.WithMeta(ctx, audit.Meta{Actor: actor()})
+
+	s := &session{cfg: cfg, out: out}
+	defer func() { s.database.Close() }()
+
+	if err := run(ctx, s, rest); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr)
+			commandUsage()
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+// actor names the operator in the audit log
+func actor() string {
+	if u, err := user.Current(); err == nil {
+		return "cli:" + u.Username
+	}
+	return "cli"
+}
+
+// exactArgs checks the number of positional arguments
+func exactArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%w: expected %d argument(s), got %d", errUsage, n, len(args))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/nurkenspashev92/emob/internal/exporter"
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatCSV   = "csv"
+)
+
+// output renders command results as an aligned table, JSON or CSV
+type output struct {
+	format string
+	w      io.Writer
+}
+
+func newOutput(format string, w io.Writer) (output, error) {
+	switch format {
+	case formatTable, formatJSON, formatCSV:
+		return output{format: format, w: w}, nil
+	}
+	return output{}, fmt.Errorf("unsupported output format %q, use table, json or csv", format)
+}
+
+// subscriptions prints a list. CSV uses the export columns so the file can be
+// fed back to the import endpoint.
+func (o output) subscriptions(list []models.Subscription) error {
+	switch o.format {
+	case formatJSON:
+		return o.json(list)
+	case formatCSV:
+		cw, err := exporter.NewCSVWriter(o.w)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			if err := cw.Write(&list[i]); err != nil {
+				return err
+			}
+		}
+		return cw.Close()
+	}
+
+	rows := make([][]string, 0, len(list))
+	for _, s := range list {
+		end, deleted := "", ""
+		if !s.EndDate.IsZero() {
+			end = s.EndDate.Format(models.DateLayout)
+		}
+		if s.DeletedAt != nil {
+			deleted = s.DeletedAt.Format(models.DateLayout)
+		}
+		rows = append(rows, []string{
+			s.ID,
+			s.ServiceName,
+			strconv.Itoa(s.Price),
+			s.UserID,
+			s.StartDate.Format(models.DateLayout),
+			end,
+			strconv.Itoa(s.Version),
+			deleted,
+		})
+	}
+	return o.table([]string{"id", "service", "price", "user", "start", "end", "version", "deleted"}, rows)
+}
+
+// subscription prints a single subscription, as an object rather than a
+// list in JSON
+func (o output) subscription(s *models.Subscription) error {
+	if o.format == formatJSON {
+		return o.json(s)
+	}
+	return o.subscriptions([]models.Subscription{*s})
+}
+
+// values prints v as JSON, or the given rows as a table or CSV
+func (o output) values(v any, header []string, rows [][]string) error {
+	switch o.format {
+	case formatJSON:
+		return o.json(v)
+	case formatCSV:
+		cw := csv.NewWriter(o.w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+	return o.table(header, rows)
+}
+
+func (o output) json(v any) error {
+	enc := json.NewEncoder(o.w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (o output) table(header []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(o.w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
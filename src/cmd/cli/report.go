@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+var reportTotal = command{
+	group:   "report",
+	name:    "total",
+	summary: "sum the prices of subscriptions starting within a period",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		from := fs.String("from", "", "period start, YYYY-MM-DD (required)")
+		to := fs.String("to", "", "period end, YYYY-MM-DD (required)")
+		userID := fs.String("user", "", "only subscriptions of this user")
+		service := fs.String("service", "", "only subscriptions to this service")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 0); err != nil {
+				return err
+			}
+			if err := parsePeriod(*from, *to); err != nil {
+				return err
+			}
+			if *userID != "" {
+				if _, err := parseID("user", *userID); err != nil {
+					return err
+				}
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			total, err := repositories.NewSubscriptionRepository(db).GetTotalSubscriptionsCost(ctx, *from, *to, *userID, *service)
+			if err != nil {
+				return err
+			}
+
+			return s.out.values(
+				map[string]float64{"total_price": total},
+				[]string{"total_price"},
+				[][]string{{strconv.FormatFloat(total, 'f', -1, 64)}},
+			)
+		}
+	},
+}
+
+// parsePeriod checks the -from and -to dates of a report
+func parsePeriod(from, to string) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("%w: -from and -to are required", errUsage)
+	}
+
+	start, err := time.Parse(models.DateLayout, from)
+	if err != nil {
+		return fmt.Errorf("%w: -from must be in YYYY-MM-DD format", errUsage)
+	}
+	end, err := time.Parse(models.DateLayout, to)
+	if err != nil {
+		return fmt.Errorf("%w: -to must be in YYYY-MM-DD format", errUsage)
+	}
+	if end.Before(start) {
+		return fmt.Errorf("%w: -to must not be before -from", errUsage)
+	}
+	return nil
+}
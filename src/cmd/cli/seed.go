@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"iter"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+	"github.com/nurkenspashev92/emob/internal/seed"
+)
+
+var seedCommand = command{
+	group:   "seed",
+	summary: "fill the database with generated subscriptions or a fixture",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		count := fs.Int("count", 1000, "number of subscriptions to generate")
+		users := fs.Int("users", 200, "number of distinct users")
+		from := fs.String("from", "", "earliest start date, YYYY-MM-DD (default two years before -to)")
+		to := fs.String("to", "", "latest start date, YYYY-MM-DD (default today)")
+		churn := fs.Float64("churn", 0.3, "share of subscriptions cancelled after a few months")
+		deleted := fs.Float64("deleted", 0.02, "share of subscriptions that are soft-deleted")
+		prices := fs.String("prices", seed.PricesCatalog, "price distribution: catalog, uniform or lognormal")
+		priceMin := fs.Int("price-min", 99, "lowest price of the uniform and lognormal distributions")
+		priceMax := fs.Int("price-max", 2999, "highest price of the uniform and lognormal distributions")
+		seedValue := fs.Uint64("seed", 1, "random seed, the same seed and dates give the same data")
+		fixture := fs.String("fixture", "", "load a fixture instead of generating data: "+strings.Join(seed.FixtureNames(), ", "))
+		reset := fs.Bool("reset", false, "delete every subscription first")
+		viaRepository := fs.Bool("via-repository", false, "create subscriptions one by one so they are audited and published, instead of COPY")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 0); err != nil {
+				return err
+			}
+
+			var (
+				rows   iter.Seq[*models.Subscription]
+				source string
+			)
+			if *fixture != "" {
+				generated := false
+				fs.Visit(func(f *flag.Flag) {
+					switch f.Name {
+					case "count", "users", "from", "to", "churn", "deleted", "prices", "price-min", "price-max", "seed":
+						generated = true
+					}
+				})
+				if generated {
+					return fmt.Errorf("%w: -fixture can not be combined with the generator flags", errUsage)
+				}
+
+				list, err := seed.Fixture(*fixture)
+				if err != nil {
+					return fmt.Errorf("%w: %v", errUsage, err)
+				}
+				rows = func(yield func(*models.Subscription) bool) {
+					for i := range list {
+						if !yield(&list[i]) {
+							return
+						}
+					}
+				}
+				source = "fixture " + *fixture
+			} else {
+				opts := seed.Options{
+					Subscriptions: *count,
+					Users:         *users,
+					Churn:         *churn,
+					Deleted:       *deleted,
+					Prices:        *prices,
+					PriceMin:      *priceMin,
+					PriceMax:      *priceMax,
+					Seed:          *seedValue,
+				}
+				var err error
+				if opts.From, opts.To, err = seedPeriod(*from, *to); err != nil {
+					return err
+				}
+				if err := opts.Validate(); err != nil {
+					return fmt.Errorf("%w: %v", errUsage, err)
+				}
+				rows = seed.Generate(opts)
+				source = "generated, seed " + strconv.FormatUint(*seedValue, 10)
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			repo := repositories.NewSubscriptionRepository(db)
+			if *reset {
+				if err := repo.DeleteAllSubscriptions(ctx); err != nil {
+					return err
+				}
+			}
+
+			method := "copy"
+			var n int64
+			if *viaRepository {
+				method = "repository"
+				n, err = createSubscriptions(ctx, db, rows)
+			} else {
+				n, err = repo.CopySubscriptions(ctx, rows)
+			}
+			if err != nil {
+				return err
+			}
+
+			return s.out.values(
+				map[string]any{"subscriptions": n, "source": source, "method": method},
+				[]string{"subscriptions", "source", "method"},
+				[][]string{{strconv.FormatInt(n, 10), source, method}},
+			)
+		}
+	},
+}
+
+// seedPeriod defaults the period to the two years up to today. Pass both
+// dates to get the same data on every run.
+func seedPeriod(from, to string) (time.Time, time.Time, error) {
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if to != "" {
+		t, err := time.Parse(models.DateLayout, to)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: -to must be in YYYY-MM-DD format", errUsage)
+		}
+		end = t
+	}
+
+	start := end.AddDate(-2, 0, 0)
+	if from != "" {
+		t, err := time.Parse(models.DateLayout, from)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: -from must be in YYYY-MM-DD format", errUsage)
+		}
+		start = t
+	}
+	return start, end, nil
+}
+
+// createSubscriptions goes through the same repository calls as the API, so
+// the audit log, outbox and webhooks see every row. IDs and creation times
+// are assigned by the database rather than taken from the generator.
+func createSubscriptions(ctx context.Context, db *pgxpool.Pool, rows iter.Seq[*models.Subscription]) (int64, error) {
+	repo := repositories.NewSubscriptionRepository(db)
+
+	var n int64
+	for row := range rows {
+		sub, err := repo.CreateSubscriptions(ctx, models.CreateSubscription{
+			ServiceName: row.ServiceName,
+			Price:       row.Price,
+			UserID:      row.UserID,
+			StartDate:   row.StartDate.Format(models.DateLayout),
+			EndDate:     row.EndDate.Format(models.DateLayout),
+		})
+		if err != nil {
+			return n, err
+		}
+		if row.DeletedAt != nil {
+			if err := repo.DeleteSubscription(ctx, sub.ID, nil); err != nil {
+				return n, err
+			}
+		}
+		n++
+	}
+	return n, nil
+}
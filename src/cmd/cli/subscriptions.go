@@ -0,0 +1,197 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+var subscriptionsList = command{
+	group:   "subscriptions",
+	name:    "list",
+	summary: "list subscriptions, newest first",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		limit := fs.Int("limit", 50, "maximum number of subscriptions")
+		offset := fs.Int("offset", 0, "number of subscriptions to skip")
+		includeDeleted := fs.Bool("include-deleted", false, "include soft-deleted subscriptions")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 0); err != nil {
+				return err
+			}
+			if *limit <= 0 || *offset < 0 {
+				return fmt.Errorf("%w: -limit must be positive and -offset not negative", errUsage)
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			list, err := repositories.NewSubscriptionRepository(db).GetAllSubscriptions(ctx, models.SubscriptionFilter{
+				Limit:          *limit,
+				Offset:         *offset,
+				IncludeDeleted: *includeDeleted,
+			})
+			if err != nil {
+				return err
+			}
+			return s.out.subscriptions(list)
+		}
+	},
+}
+
+var subscriptionsGet = command{
+	group:   "subscriptions",
+	name:    "get",
+	args:    "<id>",
+	summary: "show a subscription",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		includeDeleted := fs.Bool("include-deleted", false, "show the subscription even if it is soft-deleted")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 1); err != nil {
+				return err
+			}
+			id, err := parseID("subscription", args[0])
+			if err != nil {
+				return err
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			sub, err := repositories.NewSubscriptionRepository(db).GetSubscriptionByID(ctx, id, *includeDeleted)
+			if err != nil {
+				return subscriptionError(id, err)
+			}
+			return s.out.subscription(sub)
+		}
+	},
+}
+
+var subscriptionsCreate = command{
+	group:   "subscriptions",
+	name:    "create",
+	summary: "create a subscription",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		var body models.CreateSubscription
+		fs.StringVar(&body.ServiceName, "service", "", "service name (required)")
+		fs.IntVar(&body.Price, "price", 0, "monthly price (required)")
+		fs.StringVar(&body.UserID, "user", "", "user ID (required)")
+		fs.StringVar(&body.StartDate, "start", "", "start date, YYYY-MM-DD (required)")
+		fs.StringVar(&body.EndDate, "end", "", "end date, YYYY-MM-DD")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 0); err != nil {
+				return err
+			}
+			if err := body.Validate(); err != nil {
+				return fmt.Errorf("%w: %v", errUsage, err)
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			sub, err := repositories.NewSubscriptionRepository(db).CreateSubscriptions(ctx, body)
+			if err != nil {
+				return err
+			}
+			return s.out.subscription(sub)
+		}
+	},
+}
+
+var subscriptionsDelete = command{
+	group:   "subscriptions",
+	name:    "delete",
+	args:    "<id>...",
+	summary: "soft-delete subscriptions",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		ifMatch := fs.Int("if-match", 0, "only delete a single subscription if it is at this version")
+
+		return func(ctx context.Context, s *session, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("%w: expected at least one subscription ID", errUsage)
+			}
+			if *ifMatch != 0 && len(args) > 1 {
+				return fmt.Errorf("%w: -if-match applies to a single subscription", errUsage)
+			}
+
+			var versions []int
+			if *ifMatch != 0 {
+				versions = []int{*ifMatch}
+			}
+
+			ids := make([]string, 0, len(args))
+			for _, arg := range args {
+				id, err := parseID("subscription", arg)
+				if err != nil {
+					return err
+				}
+				ids = append(ids, id)
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			// Each deletion commits on its own, stop at the first failure and
+			// report what was done so far
+			repo := repositories.NewSubscriptionRepository(db)
+			type result struct {
+				ID     string `json:"id"`
+				Status string `json:"status"`
+			}
+			var (
+				results []result
+				rows    [][]string
+				failure error
+			)
+			for _, id := range ids {
+				if err := repo.DeleteSubscription(ctx, id, versions); err != nil {
+					failure = subscriptionError(id, err)
+					break
+				}
+				results = append(results, result{ID: id, Status: "deleted"})
+				rows = append(rows, []string{id, "deleted"})
+			}
+
+			if len(results) > 0 {
+				if err := s.out.values(results, []string{"id", "status"}, rows); err != nil {
+					return err
+				}
+			}
+			return failure
+		}
+	},
+}
+
+// parseID checks that an argument is a UUID before it reaches the database
+func parseID(kind, arg string) (string, error) {
+	if _, err := uuid.Parse(arg); err != nil {
+		return "", fmt.Errorf("%w: invalid %s ID %q", errUsage, kind, arg)
+	}
+	return arg, nil
+}
+
+func subscriptionError(id string, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrSubscriptionNotFound):
+		return fmt.Errorf("subscription %s not found", id)
+	case errors.Is(err, repositories.ErrVersionMismatch):
+		return fmt.Errorf("subscription %s is not at the expected version", id)
+	}
+	return err
+}
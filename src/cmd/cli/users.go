@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"flag"
+
+	"github.com/nurkenspashev92/emob/internal/calendar"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+var usersSubscriptions = command{
+	group:   "users",
+	name:    "subscriptions",
+	args:    "<user-id>",
+	summary: "list the live subscriptions of a user",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 1); err != nil {
+				return err
+			}
+			userID, err := parseID("user", args[0])
+			if err != nil {
+				return err
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			list, err := repositories.NewSubscriptionRepository(db).GetUserSubscriptions(ctx, userID)
+			if err != nil {
+				return err
+			}
+			return s.out.subscriptions(list)
+		}
+	},
+}
+
+var usersCalendarToken = command{
+	group:   "users",
+	name:    "calendar-token",
+	args:    "<user-id>",
+	summary: "issue a renewals feed token for a user, revoking the previous one",
+	setup: func(fs *flag.FlagSet) func(context.Context, *session, []string) error {
+		return func(ctx context.Context, s *session, args []string) error {
+			if err := exactArgs(args, 1); err != nil {
+				return err
+			}
+			userID, err := parseID("user", args[0])
+			if err != nil {
+				return err
+			}
+
+			token, hash, err := calendar.NewFeedToken()
+			if err != nil {
+				return err
+			}
+
+			db, err := s.db(ctx)
+			if err != nil {
+				return err
+			}
+
+			if err := repositories.NewCalendarTokenRepository(db).SaveTokenHash(ctx, userID, hash); err != nil {
+				return err
+			}
+
+			feedURL := calendar.FeedURL(userID, token)
+			return s.out.values(
+				map[string]string{"token": token, "feed_url": feedURL},
+				[]string{"token", "feed_url"},
+				[][]string{{token, feedURL}},
+			)
+		}
+	},
+}
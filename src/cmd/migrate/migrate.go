@@ -0,0 +1,154 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/pkg/store"
+)
+
+const usage = `usage: emob migrate [flags] <command>
+
+commands:
+  up [N]         apply all pending migrations, or the next N
+  down [N]       roll back the last N migrations, 1 by default
+  status         list migrations and whether they are applied
+  version        print the schema version
+  force VERSION  mark VERSION as applied and clear the dirty flag
+
+flags are the configuration flags of the app`
+
+// Run applies or inspects the migrations embedded in the binary
+func Run(args []string) {
+	cfg, rest, err := configs.LoadCommand("emob migrate", args)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintln(os.Stderr, usage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(rest) == 0 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	logger, err := logging.New(cfg.Log, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logger)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, cfg, rest[0], rest[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, usage)
+			os.Exit(2)
+		}
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("invalid arguments")
+
+func run(ctx context.Context, cfg *configs.Config, command string, args []string) error {
+	// Validate the arguments before connecting
+	var n int
+	switch command {
+	case "up", "down":
+		if len(args) > 1 {
+			return errUsage
+		}
+		if len(args) == 1 {
+			var err error
+			if n, err = strconv.Atoi(args[0]); err != nil || n <= 0 {
+				return fmt.Errorf("%w: N must be a positive number", errUsage)
+			}
+		}
+	case "force":
+		if len(args) != 1 {
+			return errUsage
+		}
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			return fmt.Errorf("%w: VERSION must be a migration version", errUsage)
+		}
+	case "status", "version":
+		if len(args) > 0 {
+			return errUsage
+		}
+	default:
+		return fmt.Errorf("%w: unknown command %q", errUsage, command)
+	}
+
+	migrator, err := store.NewMigrator(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+
+	switch command {
+	case "up":
+		if n == 0 {
+			return migrator.Up()
+		}
+		return migrator.Steps(n)
+	case "down":
+		return migrator.Steps(-max(n, 1))
+	case "force":
+		return migrator.Force(n)
+	case "version":
+		version, dirty, err := migrator.Version()
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	default:
+		return printStatus(migrator)
+	}
+}
+
+func printStatus(migrator *store.Migrator) error {
+	version, dirty, err := migrator.Version()
+	if err != nil {
+		return err
+	}
+	list, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, m := range list {
+		status := "pending"
+		switch {
+		case m.Version == version && dirty:
+			status = "dirty"
+		case m.Applied:
+			status = "applied"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", m.Version, m.Name, status)
+	}
+	return w.Flush()
+}
@@ -1,31 +1,101 @@
 package router
 
 import (
+	"log/slog"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/graph"
 	"github.com/nurkenspashev92/emob/internal/handler"
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/idempotency"
 	"github.com/nurkenspashev92/emob/internal/initializers"
+	"github.com/nurkenspashev92/emob/internal/metrics"
 	"github.com/nurkenspashev92/emob/internal/middleware"
+	"github.com/nurkenspashev92/emob/internal/ratelimit"
+	"github.com/nurkenspashev92/emob/internal/stream"
 )
 
-func RegisterRoutes(db *pgxpool.Pool) *fiber.App {
-	app := fiber.New(initializers.NewFiberConfig())
+// Dependencies are the long-lived services shared between the HTTP layer and
+// the background workers started by the app.
+type Dependencies struct {
+	DB          *pgxpool.Pool
+	Idempotency idempotency.Store
+	Changes     *stream.Hub
+	Logger      *slog.Logger
+	Health      *health.Registry
+}
+
+func RegisterRoutes(cfg *configs.Config, deps Dependencies) *fiber.App {
+	db := deps.DB
+	keys := auth.NewKeys(cfg.Auth)
+	app := fiber.New(initializers.NewFiberConfig(cfg.HTTP))
 
-	app.Use(middleware.CorsHandler)
+	app.Use(middleware.Tracing())
+	app.Use(middleware.RequestID(deps.Logger))
+	app.Use(middleware.SecurityHeaders(cfg.HTTP.Headers))
+	app.Use(middleware.Cors(cfg.CORS))
 	app.Use(initializers.NewLogger())
+	app.Use(middleware.Metrics())
+	app.Use(middleware.BodyLimit(cfg.HTTP.BodyLimit, map[string]int{
+		"/api/v1/subscriptions/import": cfg.HTTP.ImportBodyLimit,
+	}))
+	app.Use(middleware.Authenticate(keys))
 	app.Use(initializers.NewSwagger())
 
-	apiV1 := app.Group("/api/v1")
+	app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+	app.Get("/livez", handler.Livez(deps.Health))
+	app.Get("/readyz", handler.Readyz(deps.Health))
+	app.Get("/startupz", handler.Startupz(deps.Health))
+
+	apiV1 := app.Group("/api/v1", middleware.RateLimit(cfg.RateLimit, ratelimit.NewMemoryStore()))
+	ifMatch := middleware.RequireIfMatch(cfg.RequireIfMatch)
+	// Queries are bounded by the class of the route. Import, export and the
+	// change stream only have the server-side statement timeout.
+	timeouts := cfg.Pool.QueryTimeouts
+	read := middleware.QueryTimeout(timeouts.Read)
+	write := middleware.QueryTimeout(timeouts.Write)
+	report := middleware.QueryTimeout(timeouts.Report)
+	admin := middleware.RequireAdmin()
 	{
-		apiV1.Get("/healthcheck", handler.HealthCheck(db))
-
-		apiV1.Get("/subscriptions", handler.GetSubscriptions(db))
-		apiV1.Post("/subscriptions", handler.CreateSubscription(db))
-		apiV1.Get("/subscriptions/total", handler.GetSubscriptionsTotal(db))
-		apiV1.Get("/subscriptions/:id", handler.GetSubscription(db))
-		apiV1.Put("/subscriptions/:id", handler.UpdateSubscription(db))
-		apiV1.Delete("/subscriptions/:id", handler.DeleteSubscription(db))
+		apiV1.Get("/healthcheck", read, handler.HealthCheck(db))
+
+		apiV1.Get("/subscriptions", read, handler.GetSubscriptions(db))
+		apiV1.Post("/subscriptions", write, middleware.Idempotency(deps.Idempotency, cfg.Idempotency.TTL, cfg.Idempotency.Lease), handler.CreateSubscription(db))
+		apiV1.Post("/subscriptions\\:batch", write, handler.BatchSubscriptions(db, cfg.BatchMaxOperations, cfg.RequireIfMatch))
+		apiV1.Post("/subscriptions/import", handler.ImportSubscriptions(db, cfg.ImportChunkSize, cfg.HTTP.ImportBodyLimit))
+		apiV1.Get("/subscriptions/export", handler.ExportSubscriptions(db))
+		apiV1.Get("/subscriptions/stream", handler.StreamSubscriptionChanges(db, deps.Changes))
+		apiV1.Get("/subscriptions/total", report, handler.GetSubscriptionsTotal(db))
+		apiV1.Get("/subscriptions/:id", read, handler.GetSubscription(db))
+		apiV1.Put("/subscriptions/:id", write, ifMatch, handler.UpdateSubscription(db))
+		apiV1.Patch("/subscriptions/:id", write, ifMatch, handler.PatchSubscription(db))
+		apiV1.Delete("/subscriptions/:id", write, ifMatch, handler.DeleteSubscription(db))
+		apiV1.Post("/subscriptions/:id/restore", write, handler.RestoreSubscription(db))
+
+		apiV1.Post("/users/:id/calendar-token", write, handler.CreateCalendarToken(db))
+		apiV1.Get("/users/:id/renewals.ics", read, handler.GetRenewalsFeed(db))
+
+		apiV1.Post("/webhooks", admin, write, handler.CreateWebhook(db))
+		apiV1.Get("/webhooks", admin, read, handler.GetWebhooks(db))
+		apiV1.Get("/webhooks/:id", admin, read, handler.GetWebhook(db))
+		apiV1.Put("/webhooks/:id", admin, write, handler.UpdateWebhook(db))
+		apiV1.Delete("/webhooks/:id", admin, write, handler.DeleteWebhook(db))
+		apiV1.Get("/webhooks/:id/deliveries", admin, read, handler.GetWebhookDeliveries(db))
+		apiV1.Get("/webhooks/:id/deliveries/:delivery_id", admin, read, handler.GetWebhookDelivery(db))
+		apiV1.Post("/webhooks/:id/deliveries/:delivery_id/redeliver", admin, write, handler.RedeliverWebhookDelivery(db))
+
+		apiV1.Get("/audit", admin, read, handler.GetAuditEvents(db))
+
+		// The adaptor drops the request context, so the handler applies the
+		// report timeout and resolves the API key itself
+		graphql := adaptor.HTTPHandler(graph.NewHandler(db, keys, cfg.GraphQLComplexityLimit, timeouts.Report))
+		apiV1.Get("/graphql", graphql)
+		apiV1.Post("/graphql", graphql)
 	}
 
 	return app
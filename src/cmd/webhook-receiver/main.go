@@ -0,0 +1,57 @@
+// Command webhook-receiver is a local endpoint for trying out webhooks. It
+// verifies the signature of every request and prints the event.
+//
+//	go run ./cmd/webhook-receiver -secret whsec_... -addr :9000
+//
+// Register http://localhost:9000/ as the webhook URL and start the app with
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true, loopback receivers are refused
+// otherwise. Use -status 500 to see retries and the dead-letter state.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/webhooks"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "listen address")
+	secret := flag.String("secret", "", "webhook signing secret; empty skips verification")
+	status := flag.Int("status", http.StatusNoContent, "status code to answer with")
+	flag.Parse()
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		verdict := "unverified"
+		if *secret != "" {
+			if err := webhooks.Verify(*secret, r.Header.Get(webhooks.SignatureHeader), body, 5*time.Minute, time.Now()); err != nil {
+				log.Printf("%s %s: rejected: %v", r.Header.Get(webhooks.DeliveryHeader), r.Header.Get(webhooks.EventHeader), err)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			verdict = "signature ok"
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Write(body)
+		}
+		log.Printf("%s %s (%s)\n%s", r.Header.Get(webhooks.DeliveryHeader), r.Header.Get(webhooks.EventHeader), verdict, pretty.String())
+
+		w.WriteHeader(*status)
+	})
+
+	log.Printf("Listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -0,0 +1,36 @@
+package configs
+
+import "strings"
+
+// APIKey grants the bearer of a key the identity of Subject. Only the
+// SHA-256 of the key is configured, the key itself never is.
+type APIKey struct {
+	// Subject is the user ID for the user role, or a name for admins
+	Subject string
+	// Role is user or admin
+	Role string
+	Hash string
+}
+
+type AuthConfig struct {
+	APIKeys []APIKey
+}
+
+// newAuthConfig reads keys given as "SUBJECT:ROLE:SHA256", comma separated.
+// emob auth key prints a new key with its entry.
+func newAuthConfig(l *loader) AuthConfig {
+	var cfg AuthConfig
+	for _, raw := range l.list("AUTH_API_KEYS", "") {
+		parts := strings.Split(raw, ":")
+		if len(parts) != 3 {
+			l.invalid("AUTH_API_KEYS", "entry %q: expected SUBJECT:ROLE:SHA256", raw)
+			continue
+		}
+		cfg.APIKeys = append(cfg.APIKeys, APIKey{
+			Subject: parts[0],
+			Role:    parts[1],
+			Hash:    strings.ToLower(parts[2]),
+		})
+	}
+	return cfg
+}
@@ -0,0 +1,21 @@
+package configs
+
+type CORSConfig struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+func newCORSConfig(l *loader) CORSConfig {
+	return CORSConfig{
+		AllowOrigins:     l.list("CORS_ALLOW_ORIGINS", "*"),
+		AllowMethods:     l.list("CORS_ALLOW_METHODS", "GET,POST,PUT,DELETE,OPTIONS,PATCH"),
+		AllowHeaders:     l.list("CORS_ALLOW_HEADERS", "Accept,Content-Type,Authorization,X-API-Key,X-User-ID,If-Match,If-None-Match,Idempotency-Key,Last-Event-ID"),
+		ExposeHeaders:    l.list("CORS_EXPOSE_HEADERS", "RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After,X-Request-ID,ETag,Idempotent-Replayed"),
+		AllowCredentials: l.bool("CORS_ALLOW_CREDENTIALS", false),
+		MaxAge:           l.int("CORS_MAX_AGE", 600),
+	}
+}
@@ -1,44 +1,260 @@
 package configs
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
+	"strings"
 )
 
 type Config struct {
+	// DBURL takes precedence over the individual DB settings when set
+	DBURL      string
 	DBHost     string
 	DBPort     string
 	DBUser     string
 	DBPassword string
 	DBName     string
-	AppPort    string
+	// DBSSLMode is a libpq sslmode such as disable, require or verify-full.
+	// It only applies when set and DB_URL has none of its own.
+	DBSSLMode     string
+	DBSSLRootCert string
+	Pool          PoolConfig
+
+	AppPort     string
+	HTTP        HTTPConfig
+	RateLimit   RateLimitConfig
+	CORS        CORSConfig
+	Purge       PurgeConfig
+	Idempotency IdempotencyConfig
+	Webhooks    WebhookConfig
+	Outbox      OutboxConfig
+	GRPC        GRPCConfig
+	Tracing     TracingConfig
+	Log         LogConfig
+	Health      HealthConfig
+	Migrate     MigrateConfig
+	Auth        AuthConfig
+
+	// RequireIfMatch rejects PUT/PATCH/DELETE without an If-Match header,
+	// gRPC updates, patches and deletes without if_match_version and batch
+	// updates and deletes without if_match
+	RequireIfMatch bool
+	// BatchMaxOperations caps the size of a bulk request
+	BatchMaxOperations int
+	// ImportChunkSize is how many CSV rows are inserted per transaction
+	ImportChunkSize int
+	// GraphQLComplexityLimit rejects GraphQL queries estimated to cost more
+	GraphQLComplexityLimit int
+
+	// PrintConfig asks the app to print the effective settings and exit
+	PrintConfig bool
+
+	settings []Setting
 }
 
+// DatabaseURL returns DB_URL, or a URL built from the individual settings.
+// sslmode and sslrootcert are applied to both unless the URL sets them.
 func (c *Config) DatabaseURL() string {
-	return fmt.Sprintf(
-		"postgresql://%s:%s@%s:%s/%s?sslmode=disable",
-		c.DBUser,
-		c.DBPassword,
-		c.DBHost,
-		c.DBPort,
-		c.DBName,
-	)
+	if c.DBURL != "" && !strings.Contains(c.DBURL, "://") {
+		return c.keywordDSN()
+	}
+
+	u := &url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(c.DBUser, c.DBPassword),
+		Host:   c.DBHost + ":" + c.DBPort,
+		Path:   "/" + c.DBName,
+	}
+	if c.DBURL != "" {
+		parsed, err := url.Parse(c.DBURL)
+		if err != nil {
+			return c.DBURL
+		}
+		u = parsed
+	}
+
+	q := u.Query()
+	if c.DBSSLMode != "" && !q.Has("sslmode") {
+		q.Set("sslmode", c.DBSSLMode)
+	}
+	if c.DBSSLRootCert != "" && !q.Has("sslrootcert") {
+		q.Set("sslrootcert", c.DBSSLRootCert)
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
 }
 
-func NewConfig() *Config {
-	return &Config{
-		DBHost:     getEnv("DB_HOST", "localhost"),
-		DBPort:     getEnv("DB_PORT", "5432"),
-		DBUser:     getEnv("DB_USER", "emob"),
-		DBPassword: getEnv("DB_PASSWORD", "emob"),
-		DBName:     getEnv("DB_NAME", "emob"),
-		AppPort:    getEnv("APP_PORT", "8080"),
+// keywordDSN adds the TLS settings to a DB_URL in the keyword/value form,
+// such as "host=db user=app sslmode=require"
+func (c *Config) keywordDSN() string {
+	dsn := c.DBURL
+	if c.DBSSLMode != "" && !dsnSSLMode.MatchString(dsn) {
+		dsn += " sslmode=" + quoteDSNValue(c.DBSSLMode)
+	}
+	if c.DBSSLRootCert != "" && !dsnRootCert.MatchString(dsn) {
+		dsn += " sslrootcert=" + quoteDSNValue(c.DBSSLRootCert)
+	}
+	return dsn
+}
+
+var (
+	dsnSSLMode  = regexp.MustCompile(`(^|\s)sslmode\s*=`)
+	dsnRootCert = regexp.MustCompile(`(^|\s)sslrootcert\s*=`)
+)
+
+func quoteDSNValue(v string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(v) + "'"
+}
+
+// Settings returns every effective setting with its source and secrets
+// redacted.
+func (c *Config) Settings() []Setting {
+	return c.settings
+}
+
+// PrintSettings writes the effective settings one per line
+func (c *Config) PrintSettings(w io.Writer) {
+	for _, s := range c.settings {
+		fmt.Fprintf(w, "%s=%s\t(%s)\n", s.Key, s.Value, s.Source)
+	}
+}
+
+// Load builds the configuration from, in increasing precedence, defaults, a
+// YAML or TOML file given by -config or CONFIG_FILE, environment variables
+// and command line flags. Every setting has a flag named after its variable,
+// so DB_MAX_CONNS is set with -db-max-conns. All problems found are returned
+// together.
+func Load(name string, args []string) (*Config, error) {
+	cfg, rest, err := LoadCommand(name, args)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(rest, " "))
 	}
+	return cfg, nil
 }
 
-func getEnv(key, defaultVal string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+// LoadCommand is Load for commands taking arguments of their own, which are
+// returned in order. Flags may come before, between or after them.
+func LoadCommand(name string, args []string) (*Config, []string, error) {
+	return LoadFlags(flag.NewFlagSet(name, flag.ContinueOnError), args)
+}
+
+// LoadFlags is LoadCommand for commands with flags of their own, defined on
+// fs beforehand. The configuration flags are added next to them.
+func LoadFlags(fs *flag.FlagSet, args []string) (*Config, []string, error) {
+	// A dry run with defaults only discovers the settings to offer as flags
+	discovery := newLoader(nil, nil)
+	build(discovery)
+
+	flags := make(map[string]string)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file")
+	printConfig := fs.Bool("print-config", false, "print the effective configuration and exit")
+
+	for _, s := range discovery.effective() {
+		key := s.Key
+		flagName := strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+		usage := fmt.Sprintf("sets %s (default %q)", key, s.Value)
+		set := func(v string) error {
+			flags[key] = v
+			return nil
+		}
+
+		if discovery.kinds[key] == kindBool {
+			fs.BoolFunc(flagName, usage, set)
+		} else {
+			fs.Func(flagName, usage, set)
+		}
+	}
+
+	// flag stops at the first argument, pick it up and carry on
+	var rest []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, nil, err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		rest = append(rest, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+
+	var file map[string]string
+	if *configFile != "" {
+		var err error
+		if file, err = readFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	l := newLoader(file, flags)
+	cfg := build(l)
+	cfg.PrintConfig = *printConfig
+	cfg.settings = l.effective()
+
+	errs := append(l.errs, l.unused()...)
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, nil, &InvalidConfigError{Problems: errs}
+	}
+
+	return cfg, rest, nil
+}
+
+// InvalidConfigError lists every invalid setting
+type InvalidConfigError struct {
+	Problems []string
+}
+
+func (e *InvalidConfigError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// IsInvalidConfig reports whether err came from validation
+func IsInvalidConfig(err error) bool {
+	var target *InvalidConfigError
+	return errors.As(err, &target)
+}
+
+func build(l *loader) *Config {
+	return &Config{
+		DBURL:         l.str("DB_URL", ""),
+		DBHost:        l.str("DB_HOST", "localhost"),
+		DBPort:        l.str("DB_PORT", "5432"),
+		DBUser:        l.str("DB_USER", "emob"),
+		DBPassword:    l.str("DB_PASSWORD", "emob"),
+		DBName:        l.str("DB_NAME", "emob"),
+		DBSSLMode:     l.str("DB_SSLMODE", ""),
+		DBSSLRootCert: l.str("DB_SSLROOTCERT", ""),
+		Pool:          newPoolConfig(l),
+
+		AppPort:     l.str("APP_PORT", "8080"),
+		HTTP:        newHTTPConfig(l),
+		RateLimit:   newRateLimitConfig(l),
+		CORS:        newCORSConfig(l),
+		Purge:       newPurgeConfig(l),
+		Idempotency: newIdempotencyConfig(l),
+		Webhooks:    newWebhookConfig(l),
+		Outbox:      newOutboxConfig(l),
+		GRPC:        newGRPCConfig(l),
+		Tracing:     newTracingConfig(l),
+		Log:         newLogConfig(l),
+		Health:      newHealthConfig(l),
+		Migrate:     newMigrateConfig(l),
+		Auth:        newAuthConfig(l),
+
+		RequireIfMatch:     l.bool("REQUIRE_IF_MATCH", false),
+		BatchMaxOperations: l.int("BATCH_MAX_OPERATIONS", 1000),
+		ImportChunkSize:    l.int("IMPORT_CHUNK_SIZE", 500),
+
+		GraphQLComplexityLimit: l.int("GRAPHQL_COMPLEXITY_LIMIT", 1000),
 	}
-	return defaultVal
 }
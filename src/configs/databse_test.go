@@ -0,0 +1,74 @@
+package configs
+
+import "testing"
+
+func TestDatabaseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{
+			name: "from settings",
+			cfg:  Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: "p@ss", DBName: "emob"},
+			want: "postgresql://app:p%40ss@db:5432/emob",
+		},
+		{
+			name: "from settings with sslmode",
+			cfg:  Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBName: "emob", DBSSLMode: "require"},
+			want: "postgresql://app:@db:5432/emob?sslmode=require",
+		},
+		{
+			name: "url keeps its own sslmode",
+			cfg:  Config{DBURL: "postgres://app@db/emob?sslmode=disable", DBSSLMode: "require", DBSSLRootCert: "/ca.pem"},
+			want: "postgres://app@db/emob?sslmode=disable&sslrootcert=%2Fca.pem",
+		},
+		{
+			name: "url without sslmode is left to the driver",
+			cfg:  Config{DBURL: "postgres://app@db/emob"},
+			want: "postgres://app@db/emob",
+		},
+		{
+			name: "keyword form",
+			cfg:  Config{DBURL: "host=db user=app", DBSSLMode: "verify-full", DBSSLRootCert: "/etc/ca's.pem"},
+			want: `host=db user=app sslmode='verify-full' sslrootcert='/etc/ca\'s.pem'`,
+		},
+		{
+			name: "keyword form keeps its own sslmode",
+			cfg:  Config{DBURL: "host=db sslmode = disable", DBSSLMode: "require"},
+			want: "host=db sslmode = disable",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cfg.DatabaseURL(); got != tt.want {
+			t.Errorf("%s: DatabaseURL = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{key: "DB_PASSWORD", value: "secret", want: "[redacted]"},
+		{key: "WEBHOOK_SECRET", value: "secret", want: "[redacted]"},
+		{key: "DB_PASSWORD", value: "", want: ""},
+		{key: "DB_HOST", value: "db", want: "db"},
+		{key: "DB_URL", value: "postgres://app:secret@db/emob", want: "postgres://app:redacted@db/emob"},
+		{key: "DB_URL", value: "postgres://app@db/emob", want: "postgres://app@db/emob"},
+		{key: "DB_URL", value: "postgres://db/emob?user=app&password=secret", want: "postgres://db/emob?password=redacted&user=app"},
+		{key: "DB_URL", value: "host=db password=secret user=app", want: "host=db password=redacted user=app"},
+		{key: "DB_URL", value: `host=db password='se cr\'et' user=app`, want: "host=db password=redacted user=app"},
+		{key: "DB_URL", value: "host=db PASSWORD = secret", want: "host=db PASSWORD = redacted"},
+		{key: "DB_URL", value: "postgres://app:secret@db:port/emob", want: "[redacted]"},
+	}
+
+	for _, tt := range tests {
+		if got := redact(tt.key, tt.value); got != tt.want {
+			t.Errorf("redact(%s, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package configs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"go.yaml.in/yaml/v3"
+)
+
+// readFile loads a YAML or TOML file into settings keyed like environment
+// variables. Nested keys are joined with underscores, so
+//
+//	webhook:
+//	  poll_interval: 5s
+//
+// sets WEBHOOK_POLL_INTERVAL. Lists become comma separated values.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	var tree map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	settings := make(map[string]string)
+	flatten(settings, "", tree)
+	return settings, nil
+}
+
+func flatten(settings map[string]string, prefix string, value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			key = strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+			if prefix != "" {
+				key = prefix + "_" + key
+			}
+			flatten(settings, key, child)
+		}
+	case []any:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = fmt.Sprint(item)
+		}
+		settings[prefix] = strings.Join(items, ",")
+	case nil:
+		settings[prefix] = ""
+	default:
+		settings[prefix] = fmt.Sprint(v)
+	}
+}
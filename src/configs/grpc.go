@@ -0,0 +1,16 @@
+package configs
+
+type GRPCConfig struct {
+	Enabled bool
+	Port    string
+	// Reflection lets tools such as grpcurl discover the services
+	Reflection bool
+}
+
+func newGRPCConfig(l *loader) GRPCConfig {
+	return GRPCConfig{
+		Enabled:    l.bool("GRPC_ENABLED", true),
+		Port:       l.str("GRPC_PORT", "9090"),
+		Reflection: l.bool("GRPC_REFLECTION", true),
+	}
+}
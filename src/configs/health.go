@@ -0,0 +1,18 @@
+package configs
+
+import "time"
+
+type HealthConfig struct {
+	// CheckTimeout bounds each check of a probe
+	CheckTimeout time.Duration
+	// ShutdownDelay keeps serving after readiness turns false on shutdown,
+	// giving load balancers time to stop routing to the instance
+	ShutdownDelay time.Duration
+}
+
+func newHealthConfig(l *loader) HealthConfig {
+	return HealthConfig{
+		CheckTimeout:  l.duration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		ShutdownDelay: l.duration("HEALTH_SHUTDOWN_DELAY", 0),
+	}
+}
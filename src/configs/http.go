@@ -0,0 +1,88 @@
+package configs
+
+import "time"
+
+type HTTPConfig struct {
+	// ReadTimeout bounds reading a whole request, including streamed CSV
+	// imports, so it is generous by default
+	ReadTimeout time.Duration
+	// WriteTimeout is off by default because event streams and exports
+	// keep writing for as long as the client stays connected
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// BodyLimit caps request bodies in bytes. CSV imports have their own
+	// ImportBodyLimit, 0 leaves them unbounded.
+	BodyLimit       int
+	ImportBodyLimit int
+	// ReadBufferSize also caps the size of the request headers
+	ReadBufferSize int
+	// Concurrency is the most connections served at once
+	Concurrency int
+
+	// ProxyHeader such as X-Forwarded-For holds the client IP, it is only
+	// believed from TrustedProxies
+	ProxyHeader    string
+	TrustedProxies []string
+
+	TLS     TLSConfig
+	Headers SecurityHeadersConfig
+}
+
+// TLSConfig serves HTTPS when CertFile is set. The files are watched and
+// reloaded when they change, so renewed certificates need no restart.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables client certificates, ClientAuth is none, request,
+	// verify_if_given or require
+	ClientCAFile   string
+	ClientAuth     string
+	MinVersion     string
+	ReloadInterval time.Duration
+}
+
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != ""
+}
+
+type SecurityHeadersConfig struct {
+	// HSTSMaxAge is sent over HTTPS only, 0 disables it
+	HSTSMaxAge            time.Duration
+	HSTSIncludeSubdomains bool
+	HSTSPreload           bool
+	NoSniff               bool
+	// FrameOptions is DENY or SAMEORIGIN, empty disables it
+	FrameOptions   string
+	ReferrerPolicy string
+}
+
+func newHTTPConfig(l *loader) HTTPConfig {
+	return HTTPConfig{
+		ReadTimeout:     l.duration("HTTP_READ_TIMEOUT", 5*time.Minute),
+		WriteTimeout:    l.duration("HTTP_WRITE_TIMEOUT", 0),
+		IdleTimeout:     l.duration("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		BodyLimit:       l.int("HTTP_BODY_LIMIT", 4<<20),
+		ImportBodyLimit: l.int("HTTP_IMPORT_BODY_LIMIT", 512<<20),
+		ReadBufferSize:  l.int("HTTP_READ_BUFFER_SIZE", 8<<10),
+		Concurrency:     l.int("HTTP_CONCURRENCY", 256<<10),
+		ProxyHeader:     l.str("HTTP_PROXY_HEADER", ""),
+		TrustedProxies:  l.list("HTTP_TRUSTED_PROXIES", ""),
+		TLS: TLSConfig{
+			CertFile:       l.str("HTTP_TLS_CERT_FILE", ""),
+			KeyFile:        l.str("HTTP_TLS_KEY_FILE", ""),
+			ClientCAFile:   l.str("HTTP_TLS_CLIENT_CA_FILE", ""),
+			ClientAuth:     l.str("HTTP_TLS_CLIENT_AUTH", "none"),
+			MinVersion:     l.str("HTTP_TLS_MIN_VERSION", "1.2"),
+			ReloadInterval: l.duration("HTTP_TLS_RELOAD_INTERVAL", time.Minute),
+		},
+		Headers: SecurityHeadersConfig{
+			HSTSMaxAge:            l.duration("HTTP_HSTS_MAX_AGE", 365*24*time.Hour),
+			HSTSIncludeSubdomains: l.bool("HTTP_HSTS_INCLUDE_SUBDOMAINS", true),
+			HSTSPreload:           l.bool("HTTP_HSTS_PRELOAD", false),
+			NoSniff:               l.bool("HTTP_NOSNIFF", true),
+			FrameOptions:          l.str("HTTP_FRAME_OPTIONS", "DENY"),
+			ReferrerPolicy:        l.str("HTTP_REFERRER_POLICY", "no-referrer"),
+		},
+	}
+}
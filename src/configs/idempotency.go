@@ -0,0 +1,22 @@
+package configs
+
+import "time"
+
+type IdempotencyConfig struct {
+	// Store is either "postgres" or "memory"
+	Store           string
+	TTL             time.Duration
+	CleanupInterval time.Duration
+	// Lease is how long a request may hold its key before a retry can take
+	// it over, so a crashed request does not block the key for the TTL
+	Lease time.Duration
+}
+
+func newIdempotencyConfig(l *loader) IdempotencyConfig {
+	return IdempotencyConfig{
+		Store:           l.str("IDEMPOTENCY_STORE", "postgres"),
+		TTL:             l.duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		CleanupInterval: l.duration("IDEMPOTENCY_CLEANUP_INTERVAL", time.Hour),
+		Lease:           l.duration("IDEMPOTENCY_LEASE", time.Minute),
+	}
+}
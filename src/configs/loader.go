@@ -0,0 +1,201 @@
+package configs
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sources of a setting, from lowest to highest precedence
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
+
+// Setting is the effective value of one configuration key
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+type settingKind int
+
+const (
+	kindString settingKind = iota
+	kindBool
+)
+
+// loader resolves every key from flags, then the environment, then the
+// config file, then the default. Keys are the environment variable names;
+// files and flags are translated to them. Values that fail to parse are
+// collected instead of silently falling back to the default.
+type loader struct {
+	file  map[string]string
+	flags map[string]string
+
+	settings map[string]Setting
+	kinds    map[string]settingKind
+	errs     []string
+}
+
+func newLoader(file, flags map[string]string) *loader {
+	return &loader{
+		file:     file,
+		flags:    flags,
+		settings: make(map[string]Setting),
+		kinds:    make(map[string]settingKind),
+	}
+}
+
+func (l *loader) lookup(key, defaultVal string) string {
+	s := Setting{Key: key, Value: defaultVal, Source: SourceDefault}
+
+	if val, ok := l.flags[key]; ok {
+		s.Value, s.Source = val, SourceFlag
+	} else if val := os.Getenv(key); val != "" {
+		s.Value, s.Source = val, SourceEnv
+	} else if val, ok := l.file[key]; ok {
+		s.Value, s.Source = val, SourceFile
+	}
+
+	l.settings[key] = s
+	return s.Value
+}
+
+func (l *loader) invalid(key, format string, args ...any) {
+	s := l.settings[key]
+	l.errs = append(l.errs, fmt.Sprintf("%s (%s): %s", key, s.Source, fmt.Sprintf(format, args...)))
+}
+
+func (l *loader) str(key, defaultVal string) string {
+	return l.lookup(key, defaultVal)
+}
+
+func (l *loader) int(key string, defaultVal int) int {
+	raw := l.lookup(key, strconv.Itoa(defaultVal))
+	val, err := strconv.Atoi(raw)
+	if err != nil {
+		l.invalid(key, "%q is not an integer", raw)
+		return defaultVal
+	}
+	return val
+}
+
+func (l *loader) float(key string, defaultVal float64) float64 {
+	raw := l.lookup(key, strconv.FormatFloat(defaultVal, 'g', -1, 64))
+	val, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		l.invalid(key, "%q is not a number", raw)
+		return defaultVal
+	}
+	return val
+}
+
+func (l *loader) duration(key string, defaultVal time.Duration) time.Duration {
+	raw := l.lookup(key, defaultVal.String())
+	val, err := time.ParseDuration(raw)
+	if err != nil {
+		l.invalid(key, "%q is not a duration such as 30s or 5m", raw)
+		return defaultVal
+	}
+	return val
+}
+
+func (l *loader) bool(key string, defaultVal bool) bool {
+	l.kinds[key] = kindBool
+	raw := l.lookup(key, strconv.FormatBool(defaultVal))
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		l.invalid(key, "%q is not true or false", raw)
+		return defaultVal
+	}
+	return val
+}
+
+// list reads a comma separated value
+func (l *loader) list(key, defaultVal string) []string {
+	list := make([]string, 0)
+	for _, v := range strings.Split(l.lookup(key, defaultVal), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// unused reports file keys no setting asked for, which are most likely typos
+func (l *loader) unused() []string {
+	var errs []string
+	for key := range l.file {
+		if _, ok := l.settings[key]; !ok {
+			errs = append(errs, fmt.Sprintf("%s (file): unknown setting", key))
+		}
+	}
+	sort.Strings(errs)
+	return errs
+}
+
+// effective lists every setting sorted by key with secrets redacted
+func (l *loader) effective() []Setting {
+	list := make([]Setting, 0, len(l.settings))
+	for _, s := range l.settings {
+		s.Value = redact(s.Key, s.Value)
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Key < list[j].Key })
+	return list
+}
+
+func redact(key, value string) string {
+	if value == "" {
+		return value
+	}
+
+	for _, marker := range []string{"PASSWORD", "SECRET", "TOKEN"} {
+		if strings.Contains(key, marker) {
+			return "[redacted]"
+		}
+	}
+
+	if strings.HasSuffix(key, "_URL") {
+		return redactDSN(value)
+	}
+
+	return value
+}
+
+// dsnPassword matches the password of a keyword/value connection string,
+// quoted or not
+var dsnPassword = regexp.MustCompile(`(?i)(^|\s)(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN masks the password of a connection string, whether it is the
+// userinfo or password parameter of a URL or a keyword/value pair. A URL
+// that does not parse is masked whole.
+func redactDSN(value string) string {
+	if !strings.Contains(value, "://") {
+		return dsnPassword.ReplaceAllString(value, "${1}${2}redacted")
+	}
+
+	u, err := url.Parse(value)
+	if err != nil {
+		return "[redacted]"
+	}
+	if u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), "redacted")
+		}
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", "redacted")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
@@ -0,0 +1,15 @@
+package configs
+
+type LogConfig struct {
+	// Format is "json" or "text"
+	Format string
+	// Level is debug, info, warn or error
+	Level string
+}
+
+func newLogConfig(l *loader) LogConfig {
+	return LogConfig{
+		Format: l.str("LOG_FORMAT", "json"),
+		Level:  l.str("LOG_LEVEL", "info"),
+	}
+}
@@ -0,0 +1,18 @@
+package configs
+
+import "time"
+
+type MigrateConfig struct {
+	// OnStart applies pending migrations before serving. Otherwise the app
+	// refuses to start on an outdated schema.
+	OnStart bool
+	// LockTimeout is how long to wait for another instance that is migrating
+	LockTimeout time.Duration
+}
+
+func newMigrateConfig(l *loader) MigrateConfig {
+	return MigrateConfig{
+		OnStart:     l.bool("MIGRATE_ON_START", false),
+		LockTimeout: l.duration("MIGRATE_LOCK_TIMEOUT", time.Minute),
+	}
+}
@@ -0,0 +1,19 @@
+package configs
+
+import "time"
+
+type OutboxConfig struct {
+	// RelayEnabled starts the relay in this process. Relays on several
+	// instances take turns, only one drains the outbox at a time.
+	RelayEnabled bool
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func newOutboxConfig(l *loader) OutboxConfig {
+	return OutboxConfig{
+		RelayEnabled: l.bool("OUTBOX_RELAY_ENABLED", true),
+		PollInterval: l.duration("OUTBOX_POLL_INTERVAL", time.Second),
+		BatchSize:    l.int("OUTBOX_BATCH_SIZE", 100),
+	}
+}
@@ -0,0 +1,62 @@
+package configs
+
+import "time"
+
+type PoolConfig struct {
+	MaxConns int
+	MinConns int
+	// MaxConnLifetime recycles connections so failovers and server-side
+	// setting changes are picked up; the jitter spreads the reconnects
+	MaxConnLifetime       time.Duration
+	MaxConnLifetimeJitter time.Duration
+	MaxConnIdleTime       time.Duration
+	HealthCheckPeriod     time.Duration
+
+	// ConnectTimeout bounds a single connection attempt
+	ConnectTimeout time.Duration
+	// ConnectRetryTimeout is how long startup keeps retrying while the
+	// database is unreachable, 0 gives up after the first attempt
+	ConnectRetryTimeout time.Duration
+	ConnectBackoffBase  time.Duration
+	ConnectBackoffMax   time.Duration
+
+	// StatementTimeout is set on every connection as a server-side backstop,
+	// 0 leaves the server default
+	StatementTimeout time.Duration
+	// QueryTimeouts bound the queries of a request by the kind of work it
+	// does, 0 means no limit besides StatementTimeout
+	QueryTimeouts QueryTimeouts
+}
+
+// QueryTimeouts are per query class deadlines
+type QueryTimeouts struct {
+	// Read covers lookups and listings
+	Read time.Duration
+	// Write covers creates, updates and deletes
+	Write time.Duration
+	// Report covers aggregates and GraphQL queries
+	Report time.Duration
+}
+
+func newPoolConfig(l *loader) PoolConfig {
+	return PoolConfig{
+		MaxConns:              l.int("DB_MAX_CONNS", 10),
+		MinConns:              l.int("DB_MIN_CONNS", 0),
+		MaxConnLifetime:       l.duration("DB_MAX_CONN_LIFETIME", time.Hour),
+		MaxConnLifetimeJitter: l.duration("DB_MAX_CONN_LIFETIME_JITTER", 5*time.Minute),
+		MaxConnIdleTime:       l.duration("DB_MAX_CONN_IDLE_TIME", 30*time.Minute),
+		HealthCheckPeriod:     l.duration("DB_HEALTH_CHECK_PERIOD", time.Minute),
+
+		ConnectTimeout:      l.duration("DB_CONNECT_TIMEOUT", 5*time.Second),
+		ConnectRetryTimeout: l.duration("DB_CONNECT_RETRY_TIMEOUT", time.Minute),
+		ConnectBackoffBase:  l.duration("DB_CONNECT_BACKOFF_BASE", 500*time.Millisecond),
+		ConnectBackoffMax:   l.duration("DB_CONNECT_BACKOFF_MAX", 10*time.Second),
+
+		StatementTimeout: l.duration("DB_STATEMENT_TIMEOUT", time.Minute),
+		QueryTimeouts: QueryTimeouts{
+			Read:   l.duration("DB_QUERY_TIMEOUT_READ", 5*time.Second),
+			Write:  l.duration("DB_QUERY_TIMEOUT_WRITE", 10*time.Second),
+			Report: l.duration("DB_QUERY_TIMEOUT_REPORT", 30*time.Second),
+		},
+	}
+}
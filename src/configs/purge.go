@@ -0,0 +1,17 @@
+package configs
+
+import "time"
+
+type PurgeConfig struct {
+	Enabled   bool
+	Retention time.Duration
+	Interval  time.Duration
+}
+
+func newPurgeConfig(l *loader) PurgeConfig {
+	return PurgeConfig{
+		Enabled:   l.bool("PURGE_ENABLED", true),
+		Retention: l.duration("PURGE_RETENTION", 30*24*time.Hour),
+		Interval:  l.duration("PURGE_INTERVAL", time.Hour),
+	}
+}
@@ -0,0 +1,86 @@
+package configs
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateLimitRule struct {
+	Method   string
+	Path     string
+	Requests int
+	Period   time.Duration
+}
+
+type RateLimitConfig struct {
+	Enabled  bool
+	Requests int
+	Period   time.Duration
+	// KeyBy lists api_key, user and ip in order of preference. The first two
+	// need an authenticated API key.
+	KeyBy  []string
+	Routes []RateLimitRule
+}
+
+// newRateLimitConfig reads rate limit settings. Route rules are given as
+// "GET /api/v1/subscriptions/total=10/1m;POST /api/v1/subscriptions=30/1m".
+func newRateLimitConfig(l *loader) RateLimitConfig {
+	cfg := RateLimitConfig{
+		Enabled:  l.bool("RATE_LIMIT_ENABLED", true),
+		Requests: l.int("RATE_LIMIT_REQUESTS", 100),
+		Period:   l.duration("RATE_LIMIT_PERIOD", time.Minute),
+		KeyBy:    l.list("RATE_LIMIT_KEY_BY", "ip"),
+	}
+
+	for _, raw := range strings.Split(l.str("RATE_LIMIT_ROUTES", "GET /api/v1/subscriptions/total=10/1m"), ";") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		rule, err := parseRateLimitRule(raw)
+		if err != nil {
+			l.invalid("RATE_LIMIT_ROUTES", "rule %q: %v", raw, err)
+			continue
+		}
+		cfg.Routes = append(cfg.Routes, rule)
+	}
+
+	return cfg
+}
+
+func parseRateLimitRule(raw string) (RateLimitRule, error) {
+	route, quota, ok := strings.Cut(raw, "=")
+	if !ok {
+		return RateLimitRule{}, errors.New("expected ROUTE=REQUESTS/PERIOD")
+	}
+
+	method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+	if !ok {
+		return RateLimitRule{}, errors.New("expected METHOD PATH")
+	}
+
+	reqStr, periodStr, ok := strings.Cut(strings.TrimSpace(quota), "/")
+	if !ok {
+		return RateLimitRule{}, errors.New("expected REQUESTS/PERIOD")
+	}
+
+	requests, err := strconv.Atoi(reqStr)
+	if err != nil || requests <= 0 {
+		return RateLimitRule{}, errors.New("requests must be a positive integer")
+	}
+
+	period, err := time.ParseDuration(periodStr)
+	if err != nil || period <= 0 {
+		return RateLimitRule{}, errors.New("period must be a positive duration")
+	}
+
+	return RateLimitRule{
+		Method:   strings.ToUpper(method),
+		Path:     strings.TrimSpace(path),
+		Requests: requests,
+		Period:   period,
+	}, nil
+}
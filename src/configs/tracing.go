@@ -0,0 +1,24 @@
+package configs
+
+type TracingConfig struct {
+	// Exporter is "otlp", "stdout" or "none". Spans are still created with
+	// "none" so trace ids propagate, they are just not exported.
+	Exporter    string
+	ServiceName string
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector
+	OTLPEndpoint string
+	OTLPInsecure bool
+	// SampleRatio is the share of new traces recorded. Requests arriving with
+	// a sampled traceparent are always recorded.
+	SampleRatio float64
+}
+
+func newTracingConfig(l *loader) TracingConfig {
+	return TracingConfig{
+		Exporter:     l.str("TRACING_EXPORTER", "none"),
+		ServiceName:  l.str("TRACING_SERVICE_NAME", "emob"),
+		OTLPEndpoint: l.str("TRACING_OTLP_ENDPOINT", "localhost:4318"),
+		OTLPInsecure: l.bool("TRACING_OTLP_INSECURE", true),
+		SampleRatio:  l.float("TRACING_SAMPLE_RATIO", 1),
+	}
+}
@@ -0,0 +1,222 @@
+package configs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// validate checks values that parse but make no sense
+func (c *Config) validate() []string {
+	var errs []string
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, key+": "+fmt.Sprintf(format, args...))
+	}
+	oneOf := func(key, value string, allowed ...string) {
+		if !slices.Contains(allowed, value) {
+			fail(key, "%q must be one of %s", value, strings.Join(allowed, ", "))
+		}
+	}
+	port := func(key, value string) {
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+			fail(key, "%q is not a port number", value)
+		}
+	}
+	positive := func(key string, n int) {
+		if n <= 0 {
+			fail(key, "must be greater than zero")
+		}
+	}
+	positiveDuration := func(key string, d time.Duration) {
+		if d <= 0 {
+			fail(key, "must be a positive duration")
+		}
+	}
+	notNegative := func(key string, d time.Duration) {
+		if d < 0 {
+			fail(key, "must not be negative")
+		}
+	}
+
+	if c.DBURL == "" {
+		port("DB_PORT", c.DBPort)
+		if c.DBHost == "" {
+			fail("DB_HOST", "is required unless DB_URL is set")
+		}
+	}
+	if c.DBSSLMode != "" {
+		oneOf("DB_SSLMODE", c.DBSSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full")
+	}
+	positive("DB_MAX_CONNS", c.Pool.MaxConns)
+	if c.Pool.MinConns < 0 || c.Pool.MinConns > c.Pool.MaxConns {
+		fail("DB_MIN_CONNS", "must be between 0 and DB_MAX_CONNS")
+	}
+	notNegative("DB_MAX_CONN_LIFETIME", c.Pool.MaxConnLifetime)
+	notNegative("DB_MAX_CONN_LIFETIME_JITTER", c.Pool.MaxConnLifetimeJitter)
+	notNegative("DB_MAX_CONN_IDLE_TIME", c.Pool.MaxConnIdleTime)
+	positiveDuration("DB_HEALTH_CHECK_PERIOD", c.Pool.HealthCheckPeriod)
+	positiveDuration("DB_CONNECT_TIMEOUT", c.Pool.ConnectTimeout)
+	notNegative("DB_CONNECT_RETRY_TIMEOUT", c.Pool.ConnectRetryTimeout)
+	positiveDuration("DB_CONNECT_BACKOFF_BASE", c.Pool.ConnectBackoffBase)
+	if c.Pool.ConnectBackoffMax < c.Pool.ConnectBackoffBase {
+		fail("DB_CONNECT_BACKOFF_MAX", "must not be less than DB_CONNECT_BACKOFF_BASE")
+	}
+	notNegative("DB_STATEMENT_TIMEOUT", c.Pool.StatementTimeout)
+	queryTimeout := func(key string, d time.Duration) {
+		notNegative(key, d)
+		// A deadline cannot extend the server-side limit, only shorten it
+		if c.Pool.StatementTimeout > 0 && d > c.Pool.StatementTimeout {
+			fail(key, "must not exceed DB_STATEMENT_TIMEOUT")
+		}
+	}
+	queryTimeout("DB_QUERY_TIMEOUT_READ", c.Pool.QueryTimeouts.Read)
+	queryTimeout("DB_QUERY_TIMEOUT_WRITE", c.Pool.QueryTimeouts.Write)
+	queryTimeout("DB_QUERY_TIMEOUT_REPORT", c.Pool.QueryTimeouts.Report)
+
+	port("APP_PORT", c.AppPort)
+	notNegative("HTTP_READ_TIMEOUT", c.HTTP.ReadTimeout)
+	notNegative("HTTP_WRITE_TIMEOUT", c.HTTP.WriteTimeout)
+	notNegative("HTTP_IDLE_TIMEOUT", c.HTTP.IdleTimeout)
+	positive("HTTP_BODY_LIMIT", c.HTTP.BodyLimit)
+	if c.HTTP.ImportBodyLimit < 0 {
+		fail("HTTP_IMPORT_BODY_LIMIT", "must not be negative")
+	}
+	positive("HTTP_READ_BUFFER_SIZE", c.HTTP.ReadBufferSize)
+	positive("HTTP_CONCURRENCY", c.HTTP.Concurrency)
+	// Without a list of proxies any client could claim any IP
+	if c.HTTP.ProxyHeader != "" && len(c.HTTP.TrustedProxies) == 0 {
+		fail("HTTP_TRUSTED_PROXIES", "is required when HTTP_PROXY_HEADER is set")
+	}
+	for _, p := range c.HTTP.TrustedProxies {
+		if _, err := netip.ParseAddr(p); err != nil {
+			if _, err := netip.ParsePrefix(p); err != nil {
+				fail("HTTP_TRUSTED_PROXIES", "%q is not an IP address or CIDR range", p)
+			}
+		}
+	}
+
+	tls := c.HTTP.TLS
+	if tls.Enabled() {
+		if tls.KeyFile == "" {
+			fail("HTTP_TLS_KEY_FILE", "is required when HTTP_TLS_CERT_FILE is set")
+		}
+		oneOf("HTTP_TLS_CLIENT_AUTH", tls.ClientAuth, "none", "request", "verify_if_given", "require")
+		if tls.ClientCAFile == "" && (tls.ClientAuth == "verify_if_given" || tls.ClientAuth == "require") {
+			fail("HTTP_TLS_CLIENT_CA_FILE", "is required to verify client certificates")
+		}
+		if tls.ClientCAFile != "" && (tls.ClientAuth == "none" || tls.ClientAuth == "request") {
+			fail("HTTP_TLS_CLIENT_CA_FILE", "is only used with HTTP_TLS_CLIENT_AUTH=verify_if_given or require")
+		}
+		oneOf("HTTP_TLS_MIN_VERSION", tls.MinVersion, "1.2", "1.3")
+		positiveDuration("HTTP_TLS_RELOAD_INTERVAL", tls.ReloadInterval)
+	} else if tls.KeyFile != "" || tls.ClientCAFile != "" {
+		fail("HTTP_TLS_CERT_FILE", "is required when a key or client CA file is set")
+	}
+
+	notNegative("HTTP_HSTS_MAX_AGE", c.HTTP.Headers.HSTSMaxAge)
+	if c.HTTP.Headers.FrameOptions != "" {
+		oneOf("HTTP_FRAME_OPTIONS", c.HTTP.Headers.FrameOptions, "DENY", "SAMEORIGIN")
+	}
+
+	if c.CORS.AllowCredentials && slices.Contains(c.CORS.AllowOrigins, "*") {
+		fail("CORS_ALLOW_CREDENTIALS", `can not be combined with "*" in CORS_ALLOW_ORIGINS, list the origins`)
+	}
+
+	if c.GRPC.Enabled {
+		port("GRPC_PORT", c.GRPC.Port)
+		if c.GRPC.Port == c.AppPort {
+			fail("GRPC_PORT", "must differ from APP_PORT")
+		}
+	}
+
+	if c.RateLimit.Enabled {
+		positive("RATE_LIMIT_REQUESTS", c.RateLimit.Requests)
+		positiveDuration("RATE_LIMIT_PERIOD", c.RateLimit.Period)
+		for _, k := range c.RateLimit.KeyBy {
+			oneOf("RATE_LIMIT_KEY_BY", k, "api_key", "user", "ip")
+		}
+	}
+
+	if c.Purge.Enabled {
+		positiveDuration("PURGE_RETENTION", c.Purge.Retention)
+		positiveDuration("PURGE_INTERVAL", c.Purge.Interval)
+	}
+
+	oneOf("IDEMPOTENCY_STORE", c.Idempotency.Store, "postgres", "memory")
+	positiveDuration("IDEMPOTENCY_TTL", c.Idempotency.TTL)
+	positiveDuration("IDEMPOTENCY_CLEANUP_INTERVAL", c.Idempotency.CleanupInterval)
+	positiveDuration("IDEMPOTENCY_LEASE", c.Idempotency.Lease)
+	if c.Idempotency.Lease > c.Idempotency.TTL {
+		fail("IDEMPOTENCY_LEASE", "must not exceed IDEMPOTENCY_TTL")
+	}
+	if w := c.Pool.QueryTimeouts.Write; w > 0 && c.Idempotency.Lease <= w {
+		fail("IDEMPOTENCY_LEASE", "must exceed DB_QUERY_TIMEOUT_WRITE, or a running request could lose its key")
+	}
+
+	if c.Webhooks.WorkerEnabled {
+		positiveDuration("WEBHOOK_POLL_INTERVAL", c.Webhooks.PollInterval)
+		positive("WEBHOOK_BATCH_SIZE", c.Webhooks.BatchSize)
+		positiveDuration("WEBHOOK_TIMEOUT", c.Webhooks.Timeout)
+		positive("WEBHOOK_MAX_ATTEMPTS", c.Webhooks.MaxAttempts)
+		positiveDuration("WEBHOOK_BACKOFF_BASE", c.Webhooks.BackoffBase)
+		if c.Webhooks.BackoffMax < c.Webhooks.BackoffBase {
+			fail("WEBHOOK_BACKOFF_MAX", "must not be less than WEBHOOK_BACKOFF_BASE")
+		}
+		positiveDuration("WEBHOOK_RENEWAL_INTERVAL", c.Webhooks.RenewalInterval)
+	}
+
+	if c.Outbox.RelayEnabled {
+		positiveDuration("OUTBOX_POLL_INTERVAL", c.Outbox.PollInterval)
+		positive("OUTBOX_BATCH_SIZE", c.Outbox.BatchSize)
+	}
+
+	oneOf("TRACING_EXPORTER", c.Tracing.Exporter, "none", "stdout", "otlp")
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("TRACING_SAMPLE_RATIO", "must be between 0 and 1")
+	}
+
+	oneOf("LOG_FORMAT", strings.ToLower(c.Log.Format), "json", "text")
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		fail("LOG_LEVEL", "%q must be debug, info, warn or error", c.Log.Level)
+	}
+
+	hashes := make(map[string]bool)
+	for _, k := range c.Auth.APIKeys {
+		oneOf("AUTH_API_KEYS", k.Role, "user", "admin")
+		// A user key acts as that user, so it must name one
+		if k.Role == "user" {
+			if _, err := uuid.Parse(k.Subject); err != nil {
+				fail("AUTH_API_KEYS", "subject %q of a user key must be a user ID", k.Subject)
+			}
+		}
+		if k.Subject == "" {
+			fail("AUTH_API_KEYS", "subject must not be empty")
+		}
+		if b, err := hex.DecodeString(k.Hash); err != nil || len(b) != sha256.Size {
+			fail("AUTH_API_KEYS", "hash of %s is not a hex SHA-256", k.Subject)
+		}
+		if hashes[k.Hash] {
+			fail("AUTH_API_KEYS", "the key of %s is listed twice", k.Subject)
+		}
+		hashes[k.Hash] = true
+	}
+
+	positiveDuration("HEALTH_CHECK_TIMEOUT", c.Health.CheckTimeout)
+	notNegative("HEALTH_SHUTDOWN_DELAY", c.Health.ShutdownDelay)
+	positiveDuration("MIGRATE_LOCK_TIMEOUT", c.Migrate.LockTimeout)
+
+	positive("BATCH_MAX_OPERATIONS", c.BatchMaxOperations)
+	positive("IMPORT_CHUNK_SIZE", c.ImportChunkSize)
+	positive("GRAPHQL_COMPLEXITY_LIMIT", c.GraphQLComplexityLimit)
+
+	return errs
+}
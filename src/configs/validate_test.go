@@ -0,0 +1,85 @@
+package configs
+
+import (
+	"strings"
+	"testing"
+)
+
+// problems builds a config from settings over the defaults and returns every
+// parse and validation problem, as LoadFlags does
+func problems(settings map[string]string) []string {
+	l := newLoader(nil, settings)
+	cfg := build(l)
+	return append(l.errs, cfg.validate()...)
+}
+
+func TestValidate(t *testing.T) {
+	const hash = "0000000000000000000000000000000000000000000000000000000000000000"
+	tls := func(extra map[string]string) map[string]string {
+		settings := map[string]string{"HTTP_TLS_CERT_FILE": "cert.pem", "HTTP_TLS_KEY_FILE": "key.pem"}
+		for k, v := range extra {
+			settings[k] = v
+		}
+		return settings
+	}
+
+	tests := []struct {
+		name     string
+		settings map[string]string
+		// wantKey is the key the only problem is reported for, empty when valid
+		wantKey string
+	}{
+		{name: "defaults", settings: map[string]string{}},
+
+		{name: "sslmode left to the driver", settings: map[string]string{"DB_SSLMODE": ""}},
+		{name: "known sslmode", settings: map[string]string{"DB_SSLMODE": "verify-full"}},
+		{name: "unknown sslmode", settings: map[string]string{"DB_SSLMODE": "on"}, wantKey: "DB_SSLMODE"},
+		{name: "more min than max conns", settings: map[string]string{"DB_MIN_CONNS": "20", "DB_MAX_CONNS": "10"}, wantKey: "DB_MIN_CONNS"},
+		{name: "query timeout above statement timeout", settings: map[string]string{"DB_STATEMENT_TIMEOUT": "20s", "DB_QUERY_TIMEOUT_REPORT": "1m"}, wantKey: "DB_QUERY_TIMEOUT_REPORT"},
+
+		{name: "tls", settings: tls(nil)},
+		{name: "tls without key", settings: map[string]string{"HTTP_TLS_CERT_FILE": "cert.pem"}, wantKey: "HTTP_TLS_KEY_FILE"},
+		{name: "key without certificate", settings: map[string]string{"HTTP_TLS_KEY_FILE": "key.pem"}, wantKey: "HTTP_TLS_CERT_FILE"},
+		{name: "client certificates verified", settings: tls(map[string]string{"HTTP_TLS_CLIENT_AUTH": "require", "HTTP_TLS_CLIENT_CA_FILE": "ca.pem"})},
+		{name: "client certificates without CA", settings: tls(map[string]string{"HTTP_TLS_CLIENT_AUTH": "verify_if_given"}), wantKey: "HTTP_TLS_CLIENT_CA_FILE"},
+		{name: "CA without client auth", settings: tls(map[string]string{"HTTP_TLS_CLIENT_CA_FILE": "ca.pem"}), wantKey: "HTTP_TLS_CLIENT_CA_FILE"},
+		{name: "CA with unverified client certificates", settings: tls(map[string]string{"HTTP_TLS_CLIENT_AUTH": "request", "HTTP_TLS_CLIENT_CA_FILE": "ca.pem"}), wantKey: "HTTP_TLS_CLIENT_CA_FILE"},
+		{name: "unknown client auth", settings: tls(map[string]string{"HTTP_TLS_CLIENT_AUTH": "optional"}), wantKey: "HTTP_TLS_CLIENT_AUTH"},
+		{name: "proxy header without trusted proxies", settings: map[string]string{"HTTP_PROXY_HEADER": "X-Forwarded-For"}, wantKey: "HTTP_TRUSTED_PROXIES"},
+		{name: "trusted proxy range", settings: map[string]string{"HTTP_PROXY_HEADER": "X-Forwarded-For", "HTTP_TRUSTED_PROXIES": "10.0.0.0/8"}},
+		{name: "bad trusted proxy", settings: map[string]string{"HTTP_PROXY_HEADER": "X-Forwarded-For", "HTTP_TRUSTED_PROXIES": "proxy"}, wantKey: "HTTP_TRUSTED_PROXIES"},
+
+		{name: "credentials with wildcard origin", settings: map[string]string{"CORS_ALLOW_ORIGINS": "*", "CORS_ALLOW_CREDENTIALS": "true"}, wantKey: "CORS_ALLOW_CREDENTIALS"},
+		{name: "credentials with listed origin", settings: map[string]string{"CORS_ALLOW_ORIGINS": "https://app.example.com", "CORS_ALLOW_CREDENTIALS": "true"}},
+		{name: "grpc on the http port", settings: map[string]string{"GRPC_PORT": "8080"}, wantKey: "GRPC_PORT"},
+		{name: "unknown rate limit key", settings: map[string]string{"RATE_LIMIT_KEY_BY": "api_key,cookie"}, wantKey: "RATE_LIMIT_KEY_BY"},
+
+		{name: "lease longer than ttl", settings: map[string]string{"IDEMPOTENCY_TTL": "1m", "IDEMPOTENCY_LEASE": "2m"}, wantKey: "IDEMPOTENCY_LEASE"},
+		{name: "lease shorter than a write", settings: map[string]string{"IDEMPOTENCY_LEASE": "5s", "DB_QUERY_TIMEOUT_WRITE": "10s"}, wantKey: "IDEMPOTENCY_LEASE"},
+
+		{name: "admin key", settings: map[string]string{"AUTH_API_KEYS": "ops:admin:" + hash}},
+		{name: "user key for a user ID", settings: map[string]string{"AUTH_API_KEYS": "60601fee-2bf1-4721-ae6f-7636e79a0cba:user:" + hash}},
+		{name: "user key without a user ID", settings: map[string]string{"AUTH_API_KEYS": "alice:user:" + hash}, wantKey: "AUTH_API_KEYS"},
+		{name: "malformed key", settings: map[string]string{"AUTH_API_KEYS": "ops:admin"}, wantKey: "AUTH_API_KEYS"},
+		{name: "unknown role", settings: map[string]string{"AUTH_API_KEYS": "ops:root:" + hash}, wantKey: "AUTH_API_KEYS"},
+
+		{name: "sample ratio above one", settings: map[string]string{"TRACING_SAMPLE_RATIO": "1.5"}, wantKey: "TRACING_SAMPLE_RATIO"},
+		{name: "unknown log level", settings: map[string]string{"LOG_LEVEL": "verbose"}, wantKey: "LOG_LEVEL"},
+		{name: "unparsable duration", settings: map[string]string{"IDEMPOTENCY_TTL": "a day"}, wantKey: "IDEMPOTENCY_TTL"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := problems(tt.settings)
+			if tt.wantKey == "" {
+				if len(got) > 0 {
+					t.Fatalf("problems = %q, want none", got)
+				}
+				return
+			}
+			if len(got) != 1 || !strings.HasPrefix(got[0], tt.wantKey+" ") && !strings.HasPrefix(got[0], tt.wantKey+":") {
+				t.Fatalf("problems = %q, want one for %s", got, tt.wantKey)
+			}
+		})
+	}
+}
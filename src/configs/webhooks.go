@@ -0,0 +1,37 @@
+package configs
+
+import "time"
+
+type WebhookConfig struct {
+	// WorkerEnabled starts the delivery and renewal workers in this process
+	WorkerEnabled bool
+	PollInterval  time.Duration
+	BatchSize     int
+	Timeout       time.Duration
+	// MaxAttempts is how many failed attempts move a delivery to dead
+	MaxAttempts int
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// RenewalLead is how far ahead renewal.upcoming is announced
+	RenewalLead     time.Duration
+	RenewalInterval time.Duration
+	// AllowPrivateNetworks lets deliveries reach loopback, private and
+	// link-local addresses, for trying webhooks out locally
+	AllowPrivateNetworks bool
+}
+
+func newWebhookConfig(l *loader) WebhookConfig {
+	return WebhookConfig{
+		WorkerEnabled:   l.bool("WEBHOOK_WORKER_ENABLED", true),
+		PollInterval:    l.duration("WEBHOOK_POLL_INTERVAL", 2*time.Second),
+		BatchSize:       l.int("WEBHOOK_BATCH_SIZE", 20),
+		Timeout:         l.duration("WEBHOOK_TIMEOUT", 10*time.Second),
+		MaxAttempts:     l.int("WEBHOOK_MAX_ATTEMPTS", 10),
+		BackoffBase:     l.duration("WEBHOOK_BACKOFF_BASE", 30*time.Second),
+		BackoffMax:      l.duration("WEBHOOK_BACKOFF_MAX", 6*time.Hour),
+		RenewalLead:     l.duration("WEBHOOK_RENEWAL_LEAD", 72*time.Hour),
+		RenewalInterval: l.duration("WEBHOOK_RENEWAL_INTERVAL", time.Hour),
+
+		AllowPrivateNetworks: l.bool("WEBHOOK_ALLOW_PRIVATE_NETWORKS", false),
+	}
+}
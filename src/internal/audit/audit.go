@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	"github.com/google/uuid"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+	ActionPurge   = "purge"
+)
+
+// Meta describes who performed a mutation and from where
+type Meta struct {
+	Actor     string
+	RequestID string
+	IP        string
+}
+
+type metaKey struct{}
+
+func WithMeta(ctx context.Context, meta Meta) context.Context {
+	return context.WithValue(ctx, metaKey{}, meta)
+}
+
+// MetaFrom returns the request metadata stored in ctx, defaulting the actor
+// to "system" for mutations that do not originate from an HTTP request.
+func MetaFrom(ctx context.Context) Meta {
+	meta, _ := ctx.Value(metaKey{}).(Meta)
+	if meta.Actor == "" {
+		meta.Actor = "system"
+	}
+	return meta
+}
+
+// Actor names the caller in the audit log. Callers authenticated with an
+// API key are recorded by their subject. A user ID the caller merely claims
+// is kept with an "unverified:" prefix so it is never taken for a proven
+// identity, and anything else is anonymous.
+func Actor(ctx context.Context, claimedUserID string) string {
+	if p, ok := auth.FromContext(ctx); ok {
+		return p.Subject
+	}
+	if _, err := uuid.Parse(claimedUserID); err == nil {
+		return "unverified:" + claimedUserID
+	}
+	return "anonymous"
+}
+
+// Change is a single field difference between two snapshots
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// Diff compares the JSON representations of before and after field by field.
+// Either side may be nil for creations and deletions.
+func Diff(before, after any) (map[string]Change, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]Change)
+	for k, bv := range b {
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(bv, av) {
+			diff[k] = Change{From: bv, To: a[k]}
+		}
+	}
+	for k, av := range a {
+		if _, ok := b[k]; !ok {
+			diff[k] = Change{From: nil, To: av}
+		}
+	}
+
+	return diff, nil
+}
+
+func toMap(v any) (map[string]any, error) {
+	m := make(map[string]any)
+	if rv := reflect.ValueOf(v); v == nil || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return m, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+
+	"github.com/nurkenspashev92/emob/configs"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is the caller proven by an API key
+type Principal struct {
+	// Subject is the user ID of a user, or the name of an admin
+	Subject string
+	Role    string
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// CanActFor reports whether p may manage the data of userID
+func (p Principal) CanActFor(userID string) bool {
+	return p.IsAdmin() || (p.Role == RoleUser && p.Subject == userID)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// FromContext returns the authenticated caller, if any
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Keys looks up the principal of an API key by its hash
+type Keys map[string]Principal
+
+func NewKeys(cfg configs.AuthConfig) Keys {
+	keys := make(Keys, len(cfg.APIKeys))
+	for _, k := range cfg.APIKeys {
+		keys[k.Hash] = Principal{Subject: k.Subject, Role: k.Role}
+	}
+	return keys
+}
+
+// Authenticate returns the principal of key. Keys are compared by their
+// hash, so the lookup time says nothing about the key itself.
+func (k Keys) Authenticate(key string) (Principal, bool) {
+	p, ok := k[HashKey(key)]
+	return p, ok
+}
+
+// RequestKey picks the API key from an Authorization header with the Bearer
+// scheme, falling back to an X-API-Key header
+func RequestKey(authorization, apiKey string) string {
+	if scheme, token, _ := strings.Cut(authorization, " "); strings.EqualFold(scheme, "Bearer") {
+		return strings.TrimSpace(token)
+	}
+	return apiKey
+}
+
+// NewKey returns a random API key and the hash to configure for it
+func NewKey() (key, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	key = base64.RawURLEncoding.EncodeToString(raw)
+	return key, HashKey(key), nil
+}
+
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,106 @@
+package calendar
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+const (
+	productID = "-//EMob//Subscription Renewals//EN"
+	// maxLineOctets is the RFC 5545 limit for a content line without CRLF
+	maxLineOctets = 75
+)
+
+// RenewalFeed renders an RFC 5545 calendar with one monthly recurring event
+// per subscription, starting on its start date and ending on its end date.
+func RenewalFeed(subscriptions []models.Subscription, now time.Time) []byte {
+	var buf bytes.Buffer
+	w := &lineWriter{buf: &buf}
+
+	w.line("BEGIN:VCALENDAR")
+	w.line("VERSION:2.0")
+	w.line("PRODID:" + productID)
+	w.line("CALSCALE:GREGORIAN")
+	w.line("METHOD:PUBLISH")
+	w.line("X-WR-CALNAME:" + escapeText("Subscription renewals"))
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, s := range subscriptions {
+		summary := fmt.Sprintf("%s renewal (%d)", s.ServiceName, s.Price)
+
+		w.line("BEGIN:VEVENT")
+		w.line("UID:" + s.ID + "@emob")
+		w.line("DTSTAMP:" + stamp)
+		w.line("DTSTART;VALUE=DATE:" + s.StartDate.Format("20060102"))
+		w.line("DURATION:P1D")
+
+		w.line(renewalRule(s))
+
+		w.line("SUMMARY:" + escapeText(summary))
+		w.line("DESCRIPTION:" + escapeText(fmt.Sprintf("Service: %s\nPrice: %d", s.ServiceName, s.Price)))
+		w.line("TRANSP:TRANSPARENT")
+		w.line("END:VEVENT")
+	}
+
+	w.line("END:VCALENDAR")
+	return buf.Bytes()
+}
+
+// renewalRule repeats on the day of month the subscription started. A plain
+// monthly rule skips months without that day, so from the 29th on the rule
+// picks the last of the days 28 up to it, like the renewal notices do.
+func renewalRule(s models.Subscription) string {
+	rule := "RRULE:FREQ=MONTHLY"
+	if day := s.StartDate.Day(); day > 28 {
+		days := make([]string, 0, 4)
+		for d := 28; d <= day; d++ {
+			days = append(days, strconv.Itoa(d))
+		}
+		rule += ";BYMONTHDAY=" + strings.Join(days, ",") + ";BYSETPOS=-1"
+	}
+	if !s.EndDate.IsZero() {
+		rule += ";UNTIL=" + s.EndDate.Format("20060102")
+	}
+	return rule
+}
+
+type lineWriter struct {
+	buf *bytes.Buffer
+}
+
+// line writes a content line terminated by CRLF, folding it so that no
+// physical line exceeds 75 octets and no UTF-8 sequence is split.
+func (w *lineWriter) line(s string) {
+	limit := maxLineOctets
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+
+		w.buf.WriteString(s[:cut])
+		w.buf.WriteString("\r\n ")
+		s = s[cut:]
+		// Continuation lines start with a space, which counts toward the limit
+		limit = maxLineOctets - 1
+	}
+
+	w.buf.WriteString(s)
+	w.buf.WriteString("\r\n")
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
@@ -0,0 +1,95 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+func date(s string) time.Time {
+	t, err := time.Parse(models.DateLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestRenewalRule(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		want  string
+	}{
+		{name: "early day", start: "2026-01-15", want: "RRULE:FREQ=MONTHLY"},
+		{name: "28th exists in every month", start: "2026-01-28", want: "RRULE:FREQ=MONTHLY"},
+		{name: "29th falls back in February", start: "2026-01-29", want: "RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29;BYSETPOS=-1"},
+		{name: "30th", start: "2026-01-30", want: "RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29,30;BYSETPOS=-1"},
+		{name: "31st falls back in short months", start: "2026-01-31", want: "RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1"},
+		{name: "end date", start: "2026-01-15", end: "2026-12-31", want: "RRULE:FREQ=MONTHLY;UNTIL=20261231"},
+		{name: "31st with end date", start: "2026-01-31", end: "2026-06-30", want: "RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1;UNTIL=20260630"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := models.Subscription{StartDate: date(tt.start)}
+			if tt.end != "" {
+				s.EndDate = date(tt.end)
+			}
+			if got := renewalRule(s); got != tt.want {
+				t.Errorf("renewalRule = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenewalFeedFoldsLines(t *testing.T) {
+	feed := RenewalFeed([]models.Subscription{{
+		ID:          "550e8400-e29b-41d4-a716-446655440000",
+		ServiceName: strings.Repeat("Подписка; ", 20),
+		Price:       1999,
+		StartDate:   date("2026-01-31"),
+	}}, time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+
+	text := string(feed)
+	if !strings.HasSuffix(text, "END:VCALENDAR\r\n") {
+		t.Fatalf("feed does not end with END:VCALENDAR")
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\r\n"), "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("line of %d octets: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a UTF-8 sequence: %q", line)
+		}
+	}
+
+	unfolded := strings.ReplaceAll(text, "\r\n ", "")
+	if !strings.Contains(unfolded, "SUMMARY:"+strings.Repeat(`Подписка\; `, 20)+" renewal (1999)\r\n") {
+		t.Errorf("folded summary does not unfold to the escaped text")
+	}
+	if !strings.Contains(unfolded, "RRULE:FREQ=MONTHLY;BYMONTHDAY=28,29,30,31;BYSETPOS=-1\r\n") {
+		t.Errorf("feed is missing the renewal rule")
+	}
+}
+
+func TestEscapeText(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "Netflix", want: "Netflix"},
+		{in: `a\b`, want: `a\\b`},
+		{in: "a;b,c", want: `a\;b\,c`},
+		{in: "a\r\nb\nc", want: `a\nb\nc`},
+	}
+
+	for _, tt := range tests {
+		if got := escapeText(tt.in); got != tt.want {
+			t.Errorf("escapeText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package calendar
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewFeedToken returns a random feed token and the hash stored in its place.
+// The token itself is only shown once.
+func NewFeedToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashFeedToken(token), nil
+}
+
+func HashFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeedURL is the path of a user's renewals feed, relative to the API host
+func FeedURL(userID, token string) string {
+	return fmt.Sprintf("/api/v1/users/%s/renewals.ics?token=%s", userID, token)
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrNoHandlers keeps events in the outbox while nothing is subscribed
+var ErrNoHandlers = errors.New("no handlers subscribed to the event bus")
+
+// Handler consumes events published on a Bus
+type Handler func(ctx context.Context, e Event) error
+
+// Bus is an in-process publisher that fans events out to subscribed handlers
+// synchronously, in publish order. The app relays to a LogPublisher; a Bus is
+// for embedders that subscribe handlers before handing it to the relay.
+type Bus struct {
+	mu       sync.RWMutex
+	handlers []Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func (b *Bus) Subscribe(h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.handlers = append(b.handlers, h)
+}
+
+func (b *Bus) HasHandlers() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return len(b.handlers) > 0
+}
+
+// Publish stops at the first handler error. Handlers before it will see the
+// event again when the relay retries. Without handlers it fails, the relay
+// would otherwise delete events nobody saw.
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if len(b.handlers) == 0 {
+		return ErrNoHandlers
+	}
+
+	for _, h := range b.handlers {
+		if err := h(ctx, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+// Package events defines domain events read from the transactional outbox and
+// the publishers the relay hands them to.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Event is a committed change to an aggregate. IDs are unique, so consumers
+// can use them to drop duplicates. They are taken on insert, not on commit:
+// events of one aggregate, whose row is locked while they are written, come
+// in order, but an event may follow one of another aggregate with a higher
+// ID.
+type Event struct {
+	ID            int64           `json:"id"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	Type          string          `json:"type"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// Publisher receives events from the outbox relay. Delivery is at least once:
+// an event is published again if the relay fails before marking it done, so
+// an error return simply makes the relay retry the batch later.
+type Publisher interface {
+	Publish(ctx context.Context, e Event) error
+}
@@ -0,0 +1,61 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func NewCSVWriter(w io.Writer) (Writer, error) {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: cw}, nil
+}
+
+func (c *csvWriter) Write(s *models.Subscription) error {
+	rec := record(s)
+	for i, v := range rec {
+		rec[i] = escapeFormula(v)
+	}
+	return c.w.Write(rec)
+}
+
+// escapeFormula keeps spreadsheets from evaluating a cell as a formula. CSV
+// has no cell types, so a leading quote marks the value as text. The XLSX
+// writer stores inline strings and needs no escaping.
+func escapeFormula(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type jsonLinesWriter struct {
+	enc *json.Encoder
+}
+
+func NewJSONLinesWriter(w io.Writer) (Writer, error) {
+	return &jsonLinesWriter{enc: json.NewEncoder(w)}, nil
+}
+
+func (j *jsonLinesWriter) Write(s *models.Subscription) error {
+	return j.enc.Encode(s)
+}
+
+func (j *jsonLinesWriter) Close() error {
+	return nil
+}
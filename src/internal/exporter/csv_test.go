@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+func TestCSVWriterEscapesFormulas(t *testing.T) {
+	tests := []struct {
+		service string
+		want    string
+	}{
+		{service: "Netflix", want: "Netflix"},
+		{service: "=HYPERLINK(\"http://x\")", want: "\"'=HYPERLINK(\"\"http://x\"\")\""},
+		{service: "+1", want: "'+1"},
+		{service: "-1", want: "'-1"},
+		{service: "@SUM(A1)", want: "'@SUM(A1)"},
+		{service: "\tTab", want: "'\tTab"},
+		{service: "A=B", want: "A=B"},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := NewCSVWriter(&buf)
+		if err != nil {
+			t.Fatalf("NewCSVWriter: %v", err)
+		}
+
+		s := &models.Subscription{
+			ID:          "550e8400-e29b-41d4-a716-446655440000",
+			ServiceName: tt.service,
+			StartDate:   time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := w.Write(s); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+
+		lines := strings.Split(buf.String(), "\n")
+		prefix := s.ID + "," + tt.want + ","
+		if !strings.HasPrefix(lines[1], prefix) {
+			t.Errorf("service %q: row %q, want prefix %q", tt.service, lines[1], prefix)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+	FormatXLSX  = "xlsx"
+)
+
+// Columns is the column order used by the tabular formats
+var Columns = []string{
+	"id",
+	"service_name",
+	"price",
+	"user_id",
+	"start_date",
+	"end_date",
+	"created_at",
+	"deleted_at",
+	"version",
+}
+
+// Writer encodes subscriptions one at a time. Close must be called to flush
+// any trailing data, it does not close the underlying io.Writer.
+type Writer interface {
+	Write(s *models.Subscription) error
+	Close() error
+}
+
+// Format describes how an export is served
+type Format struct {
+	ContentType string
+	Extension   string
+	New         func(w io.Writer) (Writer, error)
+}
+
+var formats = map[string]Format{
+	FormatCSV: {
+		ContentType: "text/csv; charset=utf-8",
+		Extension:   "csv",
+		New:         NewCSVWriter,
+	},
+	FormatJSONL: {
+		ContentType: "application/x-ndjson",
+		Extension:   "jsonl",
+		New:         NewJSONLinesWriter,
+	},
+	FormatXLSX: {
+		ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		Extension:   "xlsx",
+		New:         NewXLSXWriter,
+	},
+}
+
+func Lookup(name string) (Format, error) {
+	f, ok := formats[name]
+	if !ok {
+		return Format{}, fmt.Errorf("unsupported export format %q, use csv, jsonl or xlsx", name)
+	}
+	return f, nil
+}
+
+// record renders a subscription as strings in Columns order
+func record(s *models.Subscription) []string {
+	endDate := ""
+	if !s.EndDate.IsZero() {
+		endDate = s.EndDate.Format(models.DateLayout)
+	}
+
+	deletedAt := ""
+	if s.DeletedAt != nil {
+		deletedAt = s.DeletedAt.Format(time.RFC3339)
+	}
+
+	return []string{
+		s.ID,
+		s.ServiceName,
+		strconv.Itoa(s.Price),
+		s.UserID,
+		s.StartDate.Format(models.DateLayout),
+		endDate,
+		s.CreatedAt.Format(time.RFC3339),
+		deletedAt,
+		strconv.Itoa(s.Version),
+	}
+}
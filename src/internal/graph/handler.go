@@ -0,0 +1,96 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+const (
+	// maxLimit caps the limit argument of list fields
+	maxLimit = 100
+	// totalCost is the complexity of a total, which runs an aggregate
+	// query. A list costs its children times the number of items it may
+	// return.
+	totalCost = 10
+)
+
+// NewHandler serves GraphQL queries over GET and POST. Queries whose
+// estimated cost exceeds complexityLimit are rejected before they run, the
+// rest are cancelled after queryTimeout unless it is zero. The request is
+// served behind an adaptor that drops the fiber context, so the API key is
+// resolved again from the headers.
+func NewHandler(
+	db *pgxpool.Pool,
+	keys auth.Keys,
+	complexityLimit int,
+	queryTimeout time.Duration,
+) http.Handler {
+
+	repo := repositories.NewSubscriptionRepository(db)
+
+	cfg := Config{Resolvers: &Resolver{repo: repo}}
+	cfg.Complexity.Query.Subscriptions = func(child, limit, _ int, _ bool) int {
+		return clampLimit(limit) * (child + 1)
+	}
+	cfg.Complexity.Service.Subscriptions = func(child, limit int) int {
+		return clampLimit(limit) * (child + 1)
+	}
+	cfg.Complexity.User.Subscriptions = func(child, limit int) int {
+		return clampLimit(limit) * (child + 1)
+	}
+	cfg.Complexity.Query.Total = func(int, time.Time, time.Time, *string, *string) int { return totalCost }
+	cfg.Complexity.User.Total = func(int, time.Time, time.Time, *string) int { return totalCost }
+	cfg.Complexity.Service.Total = func(int, time.Time, time.Time, *string) int { return totalCost }
+
+	srv := handler.New(NewExecutableSchema(cfg))
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.FixedComplexityLimit(complexityLimit))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if p, ok := keys.Authenticate(auth.RequestKey(r.Header.Get("Authorization"), r.Header.Get("X-API-Key"))); ok {
+			ctx = auth.WithPrincipal(ctx, p)
+		}
+		if queryTimeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, queryTimeout)
+			defer cancel()
+		}
+
+		srv.ServeHTTP(w, r.WithContext(withLoaders(ctx, repo)))
+	})
+}
+
+// allowDeleted refuses includeDeleted to callers without an admin API key
+func allowDeleted(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+	if p, ok := auth.FromContext(ctx); !ok || !p.IsAdmin() {
+		return errors.New("only admins may include deleted subscriptions")
+	}
+	return nil
+}
+
+func clampLimit(limit int) int {
+	return min(max(limit, 0), maxLimit)
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,183 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vikstrous/dataloadgen"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// loaderWait is how long a loader collects keys before querying
+const loaderWait = 2 * time.Millisecond
+
+type totalRange struct {
+	From   time.Time
+	To     time.Time
+	Filter string
+}
+
+type userTotalKey struct {
+	UserID string
+	totalRange
+}
+
+type serviceTotalKey struct {
+	ServiceName string
+	totalRange
+}
+
+type userSubscriptionsKey struct {
+	UserID string
+	Limit  int
+}
+
+type serviceSubscriptionsKey struct {
+	ServiceName string
+	Limit       int
+}
+
+// loaders batch the repository calls of one GraphQL request. They cache
+// results, so they must not outlive the request.
+type loaders struct {
+	userSubscriptions    *dataloadgen.Loader[userSubscriptionsKey, []*models.Subscription]
+	serviceSubscriptions *dataloadgen.Loader[serviceSubscriptionsKey, []*models.Subscription]
+	userTotals           *dataloadgen.Loader[userTotalKey, float64]
+	serviceTotals        *dataloadgen.Loader[serviceTotalKey, float64]
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, repo *repositories.SubscriptionRepository) context.Context {
+	wait := dataloadgen.WithWait(loaderWait)
+
+	return context.WithValue(ctx, loadersKey{}, &loaders{
+		userSubscriptions: dataloadgen.NewLoader(func(ctx context.Context, keys []userSubscriptionsKey) ([][]*models.Subscription, []error) {
+			return byGroup(keys,
+				func(k userSubscriptionsKey) int { return k.Limit },
+				func(limit int, group []userSubscriptionsKey) (func(userSubscriptionsKey) []*models.Subscription, error) {
+					ids := make([]string, len(group))
+					for i, k := range group {
+						ids[i] = k.UserID
+					}
+
+					found, err := repo.GetSubscriptionsByUsers(ctx, validUUIDs(ids), limit)
+					return func(k userSubscriptionsKey) []*models.Subscription {
+						return pointers(found[k.UserID])
+					}, err
+				})
+		}, wait),
+
+		serviceSubscriptions: dataloadgen.NewLoader(func(ctx context.Context, keys []serviceSubscriptionsKey) ([][]*models.Subscription, []error) {
+			return byGroup(keys,
+				func(k serviceSubscriptionsKey) int { return k.Limit },
+				func(limit int, group []serviceSubscriptionsKey) (func(serviceSubscriptionsKey) []*models.Subscription, error) {
+					names := make([]string, len(group))
+					for i, k := range group {
+						names[i] = k.ServiceName
+					}
+
+					found, err := repo.GetSubscriptionsByServices(ctx, names, limit)
+					return func(k serviceSubscriptionsKey) []*models.Subscription {
+						return pointers(found[strings.ToLower(k.ServiceName)])
+					}, err
+				})
+		}, wait),
+
+		userTotals: dataloadgen.NewLoader(func(ctx context.Context, keys []userTotalKey) ([]float64, []error) {
+			return byGroup(keys,
+				func(k userTotalKey) totalRange { return k.totalRange },
+				func(r totalRange, group []userTotalKey) (func(userTotalKey) float64, error) {
+					ids := make([]string, len(group))
+					for i, k := range group {
+						ids[i] = k.UserID
+					}
+
+					totals, err := repo.GetTotalsByUsers(ctx, validUUIDs(ids), r.From, r.To, r.Filter)
+					return func(k userTotalKey) float64 { return totals[k.UserID] }, err
+				})
+		}, wait),
+
+		serviceTotals: dataloadgen.NewLoader(func(ctx context.Context, keys []serviceTotalKey) ([]float64, []error) {
+			return byGroup(keys,
+				func(k serviceTotalKey) totalRange { return k.totalRange },
+				func(r totalRange, group []serviceTotalKey) (func(serviceTotalKey) float64, error) {
+					names := make([]string, len(group))
+					for i, k := range group {
+						names[i] = k.ServiceName
+					}
+
+					totals, err := repo.GetTotalsByServices(ctx, names, r.From, r.To, r.Filter)
+					return func(k serviceTotalKey) float64 { return totals[strings.ToLower(k.ServiceName)] }, err
+				})
+		}, wait),
+	})
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
+
+// byGroup splits keys that differ in more than the batched column, such as
+// the date range of a total, and runs one fetch per group.
+func byGroup[K any, G comparable, V any](
+	keys []K,
+	groupOf func(K) G,
+	fetch func(group G, keys []K) (func(K) V, error),
+) ([]V, []error) {
+
+	groups := make(map[G][]int)
+	order := make([]G, 0)
+	for i, k := range keys {
+		g := groupOf(k)
+		if _, ok := groups[g]; !ok {
+			order = append(order, g)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	values := make([]V, len(keys))
+	errs := make([]error, len(keys))
+	for _, g := range order {
+		idx := groups[g]
+		group := make([]K, len(idx))
+		for j, i := range idx {
+			group[j] = keys[i]
+		}
+
+		lookup, err := fetch(g, group)
+		for _, i := range idx {
+			if err != nil {
+				errs[i] = err
+				continue
+			}
+			values[i] = lookup(keys[i])
+		}
+	}
+
+	return values, errs
+}
+
+// validUUIDs drops ids that cannot match the uuid column, which would
+// otherwise fail the whole batch.
+func validUUIDs(ids []string) []string {
+	valid := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := uuid.Parse(id); err == nil {
+			valid = append(valid, id)
+		}
+	}
+	return valid
+}
+
+func pointers(subs []models.Subscription) []*models.Subscription {
+	out := make([]*models.Subscription, len(subs))
+	for i := range subs {
+		out[i] = &subs[i]
+	}
+	return out
+}
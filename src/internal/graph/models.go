@@ -0,0 +1,11 @@
+package graph
+
+// User groups the subscriptions sharing a user id
+type User struct {
+	ID string
+}
+
+// Service groups the subscriptions sharing a service name
+type Service struct {
+	Name string
+}
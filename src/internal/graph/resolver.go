@@ -0,0 +1,9 @@
+package graph
+
+import (
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+type Resolver struct {
+	repo *repositories.SubscriptionRepository
+}
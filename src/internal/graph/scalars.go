@@ -0,0 +1,37 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+// MarshalDate writes a date as YYYY-MM-DD. The zero time, used for a missing
+// end date, is written as null.
+func MarshalDate(t time.Time) graphql.Marshaler {
+	if t.IsZero() {
+		return graphql.Null
+	}
+
+	return graphql.WriterFunc(func(w io.Writer) {
+		io.WriteString(w, strconv.Quote(t.Format(models.DateLayout)))
+	})
+}
+
+func UnmarshalDate(v any) (time.Time, error) {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}, fmt.Errorf("date must be a string")
+	}
+
+	t, err := time.Parse(models.DateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("date must be in YYYY-MM-DD format")
+	}
+	return t, nil
+}
@@ -0,0 +1,130 @@
+package graph
+
+// This file will be automatically regenerated based on the schema, any resolver implementations
+// will be copied through when generating and any unknown code will be moved to the end.
+// Code generated by github.com/99designs/gqlgen
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// Subscription is the resolver for the subscription field.
+func (r *queryResolver) Subscription(ctx context.Context, id string, includeDeleted bool) (*models.Subscription, error) {
+	if err := allowDeleted(ctx, includeDeleted); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+
+	sub, err := r.repo.GetSubscriptionByID(ctx, id, includeDeleted)
+	if errors.Is(err, repositories.ErrSubscriptionNotFound) {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *queryResolver) Subscriptions(ctx context.Context, limit int, offset int, includeDeleted bool) ([]*models.Subscription, error) {
+	if err := allowDeleted(ctx, includeDeleted); err != nil {
+		return nil, err
+	}
+	subs, err := r.repo.GetAllSubscriptions(ctx, models.SubscriptionFilter{
+		Limit:          clampLimit(limit),
+		Offset:         max(offset, 0),
+		IncludeDeleted: includeDeleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return pointers(subs), nil
+}
+
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, id string) (*User, error) {
+	// Loader results are keyed by the canonical form returned by Postgres
+	if u, err := uuid.Parse(id); err == nil {
+		id = u.String()
+	}
+	return &User{ID: id}, nil
+}
+
+// Service is the resolver for the service field.
+func (r *queryResolver) Service(ctx context.Context, name string) (*Service, error) {
+	return &Service{Name: name}, nil
+}
+
+// Total is the resolver for the total field.
+func (r *queryResolver) Total(ctx context.Context, dateFrom time.Time, dateTo time.Time, userID *string, serviceName *string) (float64, error) {
+	return r.repo.GetTotalSubscriptionsCost(ctx,
+		dateFrom.Format(models.DateLayout),
+		dateTo.Format(models.DateLayout),
+		deref(userID),
+		deref(serviceName),
+	)
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *serviceResolver) Subscriptions(ctx context.Context, obj *Service, limit int) ([]*models.Subscription, error) {
+	return loadersFrom(ctx).serviceSubscriptions.Load(ctx, serviceSubscriptionsKey{
+		ServiceName: obj.Name,
+		Limit:       clampLimit(limit),
+	})
+}
+
+// Total is the resolver for the total field.
+func (r *serviceResolver) Total(ctx context.Context, obj *Service, dateFrom time.Time, dateTo time.Time, userID *string) (float64, error) {
+	return loadersFrom(ctx).serviceTotals.Load(ctx, serviceTotalKey{
+		ServiceName: obj.Name,
+		totalRange:  totalRange{From: dateFrom, To: dateTo, Filter: deref(userID)},
+	})
+}
+
+// User is the resolver for the user field.
+func (r *subscriptionResolver) User(ctx context.Context, obj *models.Subscription) (*User, error) {
+	return &User{ID: obj.UserID}, nil
+}
+
+// Service is the resolver for the service field.
+func (r *subscriptionResolver) Service(ctx context.Context, obj *models.Subscription) (*Service, error) {
+	return &Service{Name: obj.ServiceName}, nil
+}
+
+// Subscriptions is the resolver for the subscriptions field.
+func (r *userResolver) Subscriptions(ctx context.Context, obj *User, limit int) ([]*models.Subscription, error) {
+	return loadersFrom(ctx).userSubscriptions.Load(ctx, userSubscriptionsKey{
+		UserID: obj.ID,
+		Limit:  clampLimit(limit),
+	})
+}
+
+// Total is the resolver for the total field.
+func (r *userResolver) Total(ctx context.Context, obj *User, dateFrom time.Time, dateTo time.Time, serviceName *string) (float64, error) {
+	return loadersFrom(ctx).userTotals.Load(ctx, userTotalKey{
+		UserID:     obj.ID,
+		totalRange: totalRange{From: dateFrom, To: dateTo, Filter: deref(serviceName)},
+	})
+}
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+// Service returns ServiceResolver implementation.
+func (r *Resolver) Service() ServiceResolver { return &serviceResolver{r} }
+
+// Subscription returns SubscriptionResolver implementation.
+func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
+
+// User returns UserResolver implementation.
+func (r *Resolver) User() UserResolver { return &userResolver{r} }
+
+type queryResolver struct{ *Resolver }
+type serviceResolver struct{ *Resolver }
+type subscriptionResolver struct{ *Resolver }
+type userResolver struct{ *Resolver }
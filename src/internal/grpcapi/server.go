@@ -0,0 +1,185 @@
+// Package grpcapi exposes the subscription endpoints over gRPC. It calls the
+// same repositories as the REST handlers, so both APIs share validation,
+// auditing, outbox and webhook behaviour.
+package grpcapi
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	subscriptionv1 "github.com/nurkenspashev92/emob/pkg/pb/subscription/v1"
+)
+
+func NewServer(db *pgxpool.Pool, cfg *configs.Config) *grpc.Server {
+	authenticator := authenticator{keys: auth.NewKeys(cfg.Auth)}
+	timeouts := queryTimeouts(cfg.Pool.QueryTimeouts)
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(authenticator.unary, auditUnaryInterceptor, timeouts.unary),
+		grpc.ChainStreamInterceptor(authenticator.stream, auditStreamInterceptor),
+	)
+
+	subscriptionv1.RegisterSubscriptionServiceServer(server, NewSubscriptionService(db, cfg.RequireIfMatch))
+
+	if cfg.GRPC.Reflection {
+		reflection.Register(server)
+	}
+
+	return server
+}
+
+// authenticator resolves API keys sent as "authorization: Bearer <key>" or
+// x-api-key metadata, like middleware.Authenticate does for REST. Calls
+// without a key go through anonymously, an unknown key is refused.
+type authenticator struct {
+	keys auth.Keys
+}
+
+func (a authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	key := auth.RequestKey(first(md.Get("authorization")), first(md.Get("x-api-key")))
+	if key == "" {
+		return ctx, nil
+	}
+
+	p, ok := a.keys.Authenticate(key)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid API key")
+	}
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func (a authenticator) unary(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(
+	srv any,
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &metaStream{ServerStream: ss, ctx: ctx})
+}
+
+// auditMeta builds the same caller identity auditContext attaches to REST
+// requests, from the API key or x-user-id and x-request-id metadata, along
+// with a logger tagged with the request id.
+func auditMeta(ctx context.Context) context.Context {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	meta := audit.Meta{
+		Actor:     audit.Actor(ctx, first(md.Get("x-user-id"))),
+		RequestID: first(md.Get("x-request-id")),
+	}
+	if meta.RequestID == "" {
+		meta.RequestID = uuid.NewString()
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		meta.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.IP); err == nil {
+			meta.IP = host
+		}
+	}
+
+	ctx = logging.WithLogger(ctx, slog.Default().With("request_id", meta.RequestID))
+	return audit.WithMeta(ctx, meta)
+}
+
+// first returns the first value of a metadata key, if any
+func first(values []string) string {
+	if len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func auditUnaryInterceptor(
+	ctx context.Context,
+	req any,
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+
+	return handler(auditMeta(ctx), req)
+}
+
+func auditStreamInterceptor(
+	srv any,
+	ss grpc.ServerStream,
+	_ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+
+	return handler(srv, &metaStream{ServerStream: ss, ctx: auditMeta(ss.Context())})
+}
+
+type metaStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *metaStream) Context() context.Context {
+	return s.ctx
+}
+
+// queryTimeouts puts the deadline of their query class on unary calls, like
+// middleware.QueryTimeout does for REST routes. StreamSubscriptions runs for
+// as long as the client reads, as the export does.
+type queryTimeouts configs.QueryTimeouts
+
+func (t queryTimeouts) unary(
+	ctx context.Context,
+	req any,
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (any, error) {
+
+	var timeout time.Duration
+	switch info.FullMethod {
+	case subscriptionv1.SubscriptionService_ListSubscriptions_FullMethodName,
+		subscriptionv1.SubscriptionService_GetSubscription_FullMethodName:
+		timeout = t.Read
+	case subscriptionv1.SubscriptionService_GetTotal_FullMethodName:
+		timeout = t.Report
+	default:
+		timeout = t.Write
+	}
+
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return handler(ctx, req)
+}
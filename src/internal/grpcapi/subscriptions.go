@@ -0,0 +1,307 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+	subscriptionv1 "github.com/nurkenspashev92/emob/pkg/pb/subscription/v1"
+)
+
+type SubscriptionService struct {
+	subscriptionv1.UnimplementedSubscriptionServiceServer
+
+	repo *repositories.SubscriptionRepository
+	// requireIfMatch refuses changes without if_match_version, like
+	// REQUIRE_IF_MATCH does for REST
+	requireIfMatch bool
+}
+
+func NewSubscriptionService(db *pgxpool.Pool, requireIfMatch bool) *SubscriptionService {
+	return &SubscriptionService{
+		repo:           repositories.NewSubscriptionRepository(db),
+		requireIfMatch: requireIfMatch,
+	}
+}
+
+func (s *SubscriptionService) ListSubscriptions(
+	ctx context.Context,
+	req *subscriptionv1.ListSubscriptionsRequest,
+) (*subscriptionv1.ListSubscriptionsResponse, error) {
+
+	if err := allowDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	limit := int(req.GetLimit())
+	if limit == 0 {
+		limit = 10
+	}
+
+	subs, err := s.repo.GetAllSubscriptions(ctx, models.SubscriptionFilter{
+		Limit:          limit,
+		Offset:         int(req.GetOffset()),
+		IncludeDeleted: req.GetIncludeDeleted(),
+	})
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+
+	resp := &subscriptionv1.ListSubscriptionsResponse{
+		Subscriptions: make([]*subscriptionv1.Subscription, len(subs)),
+	}
+	for i := range subs {
+		resp.Subscriptions[i] = toProto(&subs[i])
+	}
+	return resp, nil
+}
+
+func (s *SubscriptionService) StreamSubscriptions(
+	req *subscriptionv1.StreamSubscriptionsRequest,
+	stream grpc.ServerStreamingServer[subscriptionv1.Subscription],
+) error {
+
+	if err := allowDeleted(stream.Context(), req.GetIncludeDeleted()); err != nil {
+		return err
+	}
+
+	filter := models.SubscriptionFilter{
+		Limit:          int(req.GetLimit()),
+		Offset:         int(req.GetOffset()),
+		IncludeDeleted: req.GetIncludeDeleted(),
+	}
+
+	err := s.repo.StreamSubscriptions(stream.Context(), filter, func(sub *models.Subscription) error {
+		return stream.Send(toProto(sub))
+	})
+	if err != nil {
+		return toStatus(stream.Context(), err)
+	}
+	return nil
+}
+
+func (s *SubscriptionService) GetSubscription(
+	ctx context.Context,
+	req *subscriptionv1.GetSubscriptionRequest,
+) (*subscriptionv1.Subscription, error) {
+
+	if err := allowDeleted(ctx, req.GetIncludeDeleted()); err != nil {
+		return nil, err
+	}
+
+	sub, err := s.repo.GetSubscriptionByID(ctx, req.GetId(), req.GetIncludeDeleted())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) CreateSubscription(
+	ctx context.Context,
+	req *subscriptionv1.CreateSubscriptionRequest,
+) (*subscriptionv1.Subscription, error) {
+
+	body := fromInput(req.GetSubscription())
+	if err := body.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub, err := s.repo.CreateSubscriptions(ctx, body)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) UpdateSubscription(
+	ctx context.Context,
+	req *subscriptionv1.UpdateSubscriptionRequest,
+) (*subscriptionv1.Subscription, error) {
+
+	if err := s.checkIfMatch(req.IfMatchVersion); err != nil {
+		return nil, err
+	}
+
+	body := fromInput(req.GetSubscription())
+	if err := body.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	sub, err := s.repo.UpdateSubscription(ctx, req.GetId(), body, ifMatch(req.IfMatchVersion))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) PatchSubscription(
+	ctx context.Context,
+	req *subscriptionv1.PatchSubscriptionRequest,
+) (*subscriptionv1.Subscription, error) {
+
+	if err := s.checkIfMatch(req.IfMatchVersion); err != nil {
+		return nil, err
+	}
+
+	patch := models.PatchSubscription{
+		ServiceName: req.ServiceName,
+		UserID:      req.UserId,
+		StartDate:   req.StartDate,
+		EndDate:     req.EndDate,
+	}
+	if req.Price != nil {
+		price := int(req.GetPrice())
+		patch.Price = &price
+	}
+
+	sub, err := s.repo.PatchSubscription(ctx, req.GetId(), patch, ifMatch(req.IfMatchVersion))
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) DeleteSubscription(
+	ctx context.Context,
+	req *subscriptionv1.DeleteSubscriptionRequest,
+) (*emptypb.Empty, error) {
+
+	if err := s.checkIfMatch(req.IfMatchVersion); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.DeleteSubscription(ctx, req.GetId(), ifMatch(req.IfMatchVersion)); err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *SubscriptionService) RestoreSubscription(
+	ctx context.Context,
+	req *subscriptionv1.RestoreSubscriptionRequest,
+) (*subscriptionv1.Subscription, error) {
+
+	sub, err := s.repo.RestoreSubscription(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return toProto(sub), nil
+}
+
+func (s *SubscriptionService) GetTotal(
+	ctx context.Context,
+	req *subscriptionv1.GetTotalRequest,
+) (*subscriptionv1.GetTotalResponse, error) {
+
+	if req.GetDateFrom() == "" || req.GetDateTo() == "" {
+		return nil, status.Error(codes.InvalidArgument, "date_from and date_to are required")
+	}
+
+	total, err := s.repo.GetTotalSubscriptionsCost(ctx, req.GetDateFrom(), req.GetDateTo(), req.GetUserId(), req.GetServiceName())
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &subscriptionv1.GetTotalResponse{Total: total}, nil
+}
+
+// toStatus maps repository errors to the gRPC codes matching the REST status
+// codes of the same failures.
+func toStatus(ctx context.Context, err error) error {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case errors.Is(err, repositories.ErrSubscriptionNotFound):
+		return status.Error(codes.NotFound, "subscription not found")
+	case errors.Is(err, repositories.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, repositories.ErrInvalidSubscription):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.As(err, &pgErr) && (pgErr.Code[:2] == "22" || pgErr.Code[:2] == "23"):
+		return status.Error(codes.InvalidArgument, pgErr.Message)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, "request canceled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, "request timed out")
+	}
+
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	// The error may carry SQL or connection details, it only goes to the log
+	logging.FromContext(ctx).Error("grpc request failed", "error", err)
+	return status.Error(codes.Internal, "internal error")
+}
+
+// checkIfMatch refuses a change without a version when REQUIRE_IF_MATCH is on
+func (s *SubscriptionService) checkIfMatch(version *int32) error {
+	if s.requireIfMatch && version == nil {
+		return status.Error(codes.FailedPrecondition, "if_match_version is required")
+	}
+	return nil
+}
+
+// allowDeleted refuses include_deleted to callers without an admin API key
+func allowDeleted(ctx context.Context, includeDeleted bool) error {
+	if !includeDeleted {
+		return nil
+	}
+
+	p, ok := auth.FromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "an API key is required to include deleted subscriptions")
+	}
+	if !p.IsAdmin() {
+		return status.Error(codes.PermissionDenied, "only admins may include deleted subscriptions")
+	}
+	return nil
+}
+
+func ifMatch(version *int32) []int {
+	if version == nil {
+		return nil
+	}
+	return []int{int(*version)}
+}
+
+func fromInput(in *subscriptionv1.SubscriptionInput) models.CreateSubscription {
+	return models.CreateSubscription{
+		ServiceName: in.GetServiceName(),
+		Price:       int(in.GetPrice()),
+		UserID:      in.GetUserId(),
+		StartDate:   in.GetStartDate(),
+		EndDate:     in.GetEndDate(),
+	}
+}
+
+func toProto(s *models.Subscription) *subscriptionv1.Subscription {
+	out := &subscriptionv1.Subscription{
+		Id:          s.ID,
+		ServiceName: s.ServiceName,
+		Price:       int32(s.Price),
+		UserId:      s.UserID,
+		StartDate:   s.StartDate.Format(models.DateLayout),
+		CreatedAt:   timestamppb.New(s.CreatedAt),
+		Version:     int32(s.Version),
+	}
+
+	if !s.EndDate.IsZero() {
+		out.EndDate = s.EndDate.Format(models.DateLayout)
+	}
+	if s.DeletedAt != nil {
+		out.DeletedAt = timestamppb.New(*s.DeletedAt)
+	}
+
+	return out
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// auditContext attaches the caller identity to the request context so the
+// repository can record who performed a mutation, see audit.Actor.
+func auditContext(c *fiber.Ctx) context.Context {
+	requestID, _ := c.Locals("requestid").(string)
+
+	return audit.WithMeta(c.UserContext(), audit.Meta{
+		Actor:     audit.Actor(c.UserContext(), c.Get("X-User-ID")),
+		RequestID: requestID,
+		IP:        c.IP(),
+	})
+}
+
+// GetAuditEvents godoc
+// @Summary      Get audit events
+// @Description  Returns recorded mutations, newest first. Needs an admin API key.
+// @Tags         Audit
+// @Accept       json
+// @Produce      json
+// @Param        entity_id  query     string  false  "Entity ID"
+// @Param        actor      query     string  false  "Actor"
+// @Param        from       query     string  false  "From (RFC3339 or YYYY-MM-DD)"
+// @Param        to         query     string  false  "To (RFC3339 or YYYY-MM-DD)"
+// @Param        limit      query     int     false  "Limit"   default(50)
+// @Param        offset     query     int     false  "Offset"  default(0)
+// @Success      200        {array}   models.AuditEvent
+// @Failure      400        {object}  map[string]string
+// @Failure      401        {object}  map[string]string
+// @Failure      403        {object}  map[string]string
+// @Failure      500        {object}  map[string]string
+// @Router       /api/v1/audit [get]
+func GetAuditEvents(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := models.AuditFilter{
+			EntityID: c.Query("entity_id"),
+			Actor:    c.Query("actor"),
+			Limit:    c.QueryInt("limit", 50),
+			Offset:   c.QueryInt("offset", 0),
+		}
+
+		if filter.EntityID != "" {
+			if _, err := uuid.Parse(filter.EntityID); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Invalid entity_id: must be a UUID",
+				})
+			}
+		}
+
+		var err error
+		if filter.From, err = parseTimeParam(c.Query("from"), false); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid from: " + err.Error(),
+			})
+		}
+		if filter.To, err = parseTimeParam(c.Query("to"), true); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid to: " + err.Error(),
+			})
+		}
+
+		repo := repositories.NewAuditRepository(db)
+		events, err := repo.GetAuditEvents(c.UserContext(), filter)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		return c.JSON(events)
+	}
+}
+
+// parseTimeParam accepts RFC3339 timestamps or plain dates. A plain date used
+// as an upper bound covers the whole day.
+func parseTimeParam(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errors.New("expected RFC3339 or YYYY-MM-DD")
+	}
+
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// BatchSubscriptions godoc
+// @Summary      Bulk create, update and delete subscriptions
+// @Description  Applies mixed operations either all-or-nothing in one transaction (atomic) or one by one (partial). With REQUIRE_IF_MATCH every update and delete needs if_match.
+// @Tags         Subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        body  body      models.BatchRequest  true  "Batch operations"
+// @Success      200   {object}  models.BatchResponse
+// @Success      207   {object}  models.BatchResponse
+// @Failure      400   {object}  map[string]string
+// @Failure      422   {object}  models.BatchResponse
+// @Failure      428   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/subscriptions:batch [post]
+func BatchSubscriptions(db *pgxpool.Pool, maxOperations int, requireIfMatch bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var body models.BatchRequest
+		if err := c.BodyParser(&body); err != nil {
+			logging.FromContext(c.UserContext()).Debug("invalid request body", "error", err)
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+
+		if body.Mode == "" {
+			body.Mode = models.BatchModeAtomic
+		}
+
+		if err := validateBatch(body, maxOperations, requireIfMatch); err != nil {
+			status := fiber.StatusBadRequest
+			if errors.Is(err, errIfMatchRequired) {
+				status = fiber.StatusPreconditionRequired
+			}
+			return c.Status(status).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		repo := repositories.NewSubscriptionRepository(db)
+		ctx := auditContext(c)
+
+		var outcomes []repositories.BatchOutcome
+		status := fiber.StatusOK
+
+		if body.Mode == models.BatchModeAtomic {
+			var err error
+			outcomes, err = repo.ApplyBatchAtomic(ctx, body.Operations)
+			if err != nil && outcomes == nil {
+				logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status":  "error",
+					"message": err.Error(),
+				})
+			}
+			if err != nil {
+				status = fiber.StatusUnprocessableEntity
+			}
+		} else {
+			outcomes = repo.ApplyBatchPartial(ctx, body.Operations)
+		}
+
+		resp := models.BatchResponse{
+			Mode:    body.Mode,
+			Results: make([]models.BatchItemResult, len(outcomes)),
+		}
+
+		for i, out := range outcomes {
+			op := body.Operations[i].Op
+			item := models.BatchItemResult{
+				Index:        i,
+				Op:           op,
+				Status:       batchItemStatus(op, out.Err),
+				Subscription: out.Subscription,
+			}
+
+			if out.Err != nil {
+				item.Error = out.Err.Error()
+				resp.Failed++
+			} else {
+				resp.Succeeded++
+			}
+
+			resp.Results[i] = item
+		}
+
+		if body.Mode == models.BatchModePartial && resp.Failed > 0 {
+			status = fiber.StatusMultiStatus
+		}
+
+		return c.Status(status).JSON(resp)
+	}
+}
+
+// errIfMatchRequired is the batch counterpart of a missing If-Match header
+var errIfMatchRequired = errors.New("if_match is required")
+
+func validateBatch(body models.BatchRequest, maxOperations int, requireIfMatch bool) error {
+	if body.Mode != models.BatchModeAtomic && body.Mode != models.BatchModePartial {
+		return errors.New("mode must be atomic or partial")
+	}
+
+	if len(body.Operations) == 0 {
+		return errors.New("operations must not be empty")
+	}
+
+	if len(body.Operations) > maxOperations {
+		return fmt.Errorf("at most %d operations are allowed per batch", maxOperations)
+	}
+
+	seen := make(map[string]int)
+	for i, op := range body.Operations {
+		switch op.Op {
+		case models.BatchOpCreate:
+			if op.Body == nil {
+				return fmt.Errorf("operation %d: body is required", i)
+			}
+		case models.BatchOpUpdate:
+			if op.ID == "" || op.Body == nil {
+				return fmt.Errorf("operation %d: id and body are required", i)
+			}
+		case models.BatchOpDelete:
+			if op.ID == "" {
+				return fmt.Errorf("operation %d: id is required", i)
+			}
+		default:
+			return fmt.Errorf("operation %d: op must be create, update or delete", i)
+		}
+
+		if op.Body != nil {
+			if err := op.Body.Validate(); err != nil {
+				return fmt.Errorf("operation %d: %w", i, err)
+			}
+		}
+
+		if requireIfMatch && op.Op != models.BatchOpCreate && op.IfMatch == nil {
+			return fmt.Errorf("operation %d: %w", i, errIfMatchRequired)
+		}
+
+		// Row locks are taken up front, so an atomic batch cannot touch a row twice
+		if op.ID != "" && body.Mode == models.BatchModeAtomic {
+			if j, ok := seen[op.ID]; ok {
+				return fmt.Errorf("operation %d: subscription %s is already changed by operation %d", i, op.ID, j)
+			}
+			seen[op.ID] = i
+		}
+	}
+
+	return nil
+}
+
+func batchItemStatus(op string, err error) int {
+	var pgErr *pgconn.PgError
+
+	switch {
+	case err == nil && op == models.BatchOpCreate:
+		return fiber.StatusCreated
+	case err == nil && op == models.BatchOpDelete:
+		return fiber.StatusNoContent
+	case err == nil:
+		return fiber.StatusOK
+	case errors.Is(err, repositories.ErrBatchRolledBack):
+		return fiber.StatusFailedDependency
+	case errors.Is(err, repositories.ErrSubscriptionNotFound):
+		return fiber.StatusNotFound
+	case errors.Is(err, repositories.ErrVersionMismatch):
+		return fiber.StatusPreconditionFailed
+	case errors.As(err, &pgErr) && (pgErr.Code[:2] == "22" || pgErr.Code[:2] == "23"):
+		// Data exceptions and constraint violations are caused by the input
+		return fiber.StatusBadRequest
+	case errors.Is(err, repositories.ErrInvalidSubscription):
+		return fiber.StatusBadRequest
+	default:
+		return fiber.StatusInternalServerError
+	}
+}
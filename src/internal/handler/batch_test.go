@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+func TestValidateBatchIfMatch(t *testing.T) {
+	const id = "550e8400-e29b-41d4-a716-446655440000"
+	version := 3
+	body := &models.CreateSubscription{
+		ServiceName: "Netflix",
+		Price:       1999,
+		UserID:      "60601fee-2bf1-4721-ae6f-7636e79a0cba",
+		StartDate:   "2026-01-01",
+	}
+
+	tests := []struct {
+		name           string
+		op             models.BatchOperation
+		requireIfMatch bool
+		wantErr        error
+	}{
+		{name: "create never needs if_match", op: models.BatchOperation{Op: models.BatchOpCreate, Body: body}, requireIfMatch: true},
+		{name: "update without if_match", op: models.BatchOperation{Op: models.BatchOpUpdate, ID: id, Body: body}, requireIfMatch: true, wantErr: errIfMatchRequired},
+		{name: "delete without if_match", op: models.BatchOperation{Op: models.BatchOpDelete, ID: id}, requireIfMatch: true, wantErr: errIfMatchRequired},
+		{name: "update with if_match", op: models.BatchOperation{Op: models.BatchOpUpdate, ID: id, IfMatch: &version, Body: body}, requireIfMatch: true},
+		{name: "delete with if_match", op: models.BatchOperation{Op: models.BatchOpDelete, ID: id, IfMatch: &version}, requireIfMatch: true},
+		{name: "if_match is optional when not required", op: models.BatchOperation{Op: models.BatchOpDelete, ID: id}},
+	}
+
+	for _, tt := range tests {
+		req := models.BatchRequest{Mode: models.BatchModeAtomic, Operations: []models.BatchOperation{tt.op}}
+		err := validateBatch(req, 10, tt.requireIfMatch)
+		if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+			t.Errorf("%s: validateBatch = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/calendar"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// CreateCalendarToken godoc
+// @Summary      Issue calendar feed token
+// @Description  Generates a new secret token for the user's renewals feed, revoking the previous one. The token is only shown once.
+// @Tags         Calendar
+// @Produce      json
+// @Param        id             path      string  true  "User ID"
+// @Param        Authorization  header    string  true  "Bearer API key of the user or an admin"
+// @Success      201            {object}  map[string]string
+// @Failure      400            {object}  map[string]string
+// @Failure      401            {object}  map[string]string
+// @Failure      403            {object}  map[string]string
+// @Failure      500            {object}  map[string]string
+// @Router       /api/v1/users/{id}/calendar-token [post]
+func CreateCalendarToken(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Params("id")
+		if _, err := uuid.Parse(userID); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid user ID",
+			})
+		}
+
+		// The token reads the user's subscriptions, only they or an admin
+		// may issue one
+		p, ok := auth.FromContext(c.UserContext())
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An API key is required",
+			})
+		}
+		if !p.CanActFor(userID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Tokens can only be issued for yourself",
+			})
+		}
+
+		token, hash, err := calendar.NewFeedToken()
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Failed to generate token",
+			})
+		}
+
+		repo := repositories.NewCalendarTokenRepository(db)
+		if err := repo.SaveTokenHash(c.UserContext(), userID, hash); err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"token":    token,
+			"feed_url": calendar.FeedURL(userID, token),
+		})
+	}
+}
+
+// GetRenewalsFeed godoc
+// @Summary      Renewals calendar feed
+// @Description  RFC 5545 feed with a monthly recurring event per subscription of the user
+// @Tags         Calendar
+// @Produce      text/calendar
+// @Param        id     path      string  true  "User ID"
+// @Param        token  query     string  true  "Feed token"
+// @Success      200    {string}  string
+// @Failure      401    {object}  map[string]string
+// @Failure      500    {object}  map[string]string
+// @Router       /api/v1/users/{id}/renewals.ics [get]
+func GetRenewalsFeed(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID := c.Params("id")
+		token := c.Query("token")
+
+		unauthorized := func() error {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid or missing feed token",
+			})
+		}
+
+		if _, err := uuid.Parse(userID); err != nil || token == "" {
+			return unauthorized()
+		}
+
+		tokens := repositories.NewCalendarTokenRepository(db)
+		stored, err := tokens.GetTokenHash(c.UserContext(), userID)
+		if errors.Is(err, repositories.ErrFeedTokenNotFound) {
+			return unauthorized()
+		}
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		if subtle.ConstantTimeCompare([]byte(stored), []byte(calendar.HashFeedToken(token))) != 1 {
+			return unauthorized()
+		}
+
+		repo := repositories.NewSubscriptionRepository(db)
+		subscriptions, err := repo.GetUserSubscriptions(c.UserContext(), userID)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		c.Set(fiber.HeaderContentType, "text/calendar; charset=utf-8")
+		c.Set(fiber.HeaderContentDisposition, `inline; filename="renewals.ics"`)
+		return c.Send(calendar.RenewalFeed(subscriptions, time.Now()))
+	}
+}
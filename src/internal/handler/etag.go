@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersions parses the If-Match header into subscription versions.
+// A missing header or "*" yields no constraint. ok is false when the header is
+// present but none of its entity tags can ever match, since If-Match uses
+// strong comparison and weak tags are rejected.
+func ifMatchVersions(c *fiber.Ctx) (versions []int, ok bool) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return nil, true
+	}
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+
+		v, err := strconv.Atoi(strings.Trim(tag, `"`))
+		if err != nil {
+			continue
+		}
+		versions = append(versions, v)
+	}
+
+	return versions, len(versions) > 0
+}
+
+// ifNoneMatch reports whether the If-None-Match header matches the current
+// version using weak comparison.
+func ifNoneMatch(c *fiber.Ctx, version int) bool {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfNoneMatch))
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+func preconditionFailed(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusPreconditionFailed).JSON(fiber.Map{
+		"status":  "error",
+		"message": "Subscription was modified, fetch the latest version and retry",
+	})
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"bufio"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/exporter"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// exportFlushEvery controls how often buffered rows are pushed to the client
+const exportFlushEvery = 1000
+
+// ExportSubscriptions godoc
+// @Summary      Export subscriptions
+// @Description  Streams every subscription matching the list filters as CSV, JSON Lines or XLSX. CSV cells starting with =, +, -, @, tab or carriage return get a leading ' so spreadsheets read them as text.
+// @Tags         Subscriptions
+// @Produce      text/csv
+// @Produce      application/x-ndjson
+// @Produce      application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param        format           query     string  false  "Export format"  Enums(csv, jsonl, xlsx)  default(csv)
+// @Param        limit            query     int     false  "Limit, 0 exports everything"  default(0)
+// @Param        offset           query     int     false  "Offset"  default(0)
+// @Param        include_deleted  query     bool    false  "Include soft-deleted subscriptions, needs an admin API key"  default(false)
+// @Success      200              {file}    file
+// @Failure      400              {object}  map[string]string
+// @Failure      401              {object}  map[string]string
+// @Failure      403              {object}  map[string]string
+// @Router       /api/v1/subscriptions/export [get]
+func ExportSubscriptions(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		format, err := exporter.Lookup(c.Query("format", exporter.FormatCSV))
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		deleted, ok := includeDeleted(c)
+		if !ok {
+			return nil
+		}
+
+		filter := models.SubscriptionFilter{
+			Limit:          c.QueryInt("limit", 0),
+			Offset:         c.QueryInt("offset", 0),
+			IncludeDeleted: deleted,
+		}
+
+		// The status is sent before the first row, a bad page has to be
+		// refused here
+		if filter.Limit < 0 || filter.Offset < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "limit and offset must not be negative",
+			})
+		}
+
+		filename := fmt.Sprintf("subscriptions-%s.%s", time.Now().Format("2006-01-02"), format.Extension)
+		c.Set(fiber.HeaderContentType, format.ContentType)
+		c.Set(fiber.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+
+		repo := repositories.NewSubscriptionRepository(db)
+
+		// The writer runs after the handler returns, so it cannot use the
+		// fasthttp request context; the user context only carries the trace.
+		// A failed flush means the client went away.
+		ctx := c.UserContext()
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			enc, err := format.New(w)
+			if err != nil {
+				logging.FromContext(ctx).Error("export failed", "error", err)
+				return
+			}
+
+			written := 0
+			err = repo.StreamSubscriptions(ctx, filter, func(s *models.Subscription) error {
+				if err := enc.Write(s); err != nil {
+					return err
+				}
+
+				written++
+				if written%exportFlushEvery == 0 {
+					return w.Flush()
+				}
+				return nil
+			})
+			if err != nil {
+				logging.FromContext(ctx).Error("export failed", "error", err)
+				return
+			}
+
+			if err := enc.Close(); err != nil {
+				logging.FromContext(ctx).Error("export failed", "error", err)
+				return
+			}
+			if err := w.Flush(); err != nil {
+				logging.FromContext(ctx).Error("export failed", "error", err)
+			}
+		})
+
+		return nil
+	}
+}
@@ -16,7 +16,7 @@ import (
 // @Router       /healthcheck [get]
 func HealthCheck(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		if err := db.Ping(c.Context()); err != nil {
+		if err := db.Ping(c.UserContext()); err != nil {
 			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 				"status":  "fail",
 				"message": "database not reachable",
@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/importer"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// ImportSubscriptions godoc
+// @Summary      Import subscriptions from CSV
+// @Description  Reads a CSV file (raw text/csv body or multipart field "file") row by row, validates every row like CreateSubscription and stores the valid ones in chunks. An empty or missing end_date imports an open-ended subscription
+// @Tags         Subscriptions
+// @Accept       text/csv
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        dry_run      query     bool    false  "Only validate, do not store"  default(false)
+// @Param        delimiter    query     string  false  "Field delimiter, a single character or \"tab\""  default(,)
+// @Param        date_format  query     string  false  "Date format using YYYY, MM and DD"  default(YYYY-MM-DD)
+// @Param        columns      query     string  false  "Column mapping, e.g. service_name=Service,price=Amount"
+// @Param        report       query     string  false  "errors lists only failed rows, full lists every row"  Enums(errors, full)  default(errors)
+// @Param        file         formData  file    false  "CSV file"
+// @Success      200          {object}  models.ImportReport
+// @Failure      400          {object}  map[string]string
+// @Failure      413          {object}  map[string]string
+// @Router       /api/v1/subscriptions/import [post]
+func ImportSubscriptions(db *pgxpool.Pool, chunkSize, bodyLimit int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		opts, err := importOptions(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		input, err := importInput(c, bodyLimit)
+		if errors.Is(err, errBodyTooLarge) {
+			return importTooLarge(c, bodyLimit)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+		defer input.Close()
+
+		reader, err := importer.NewReader(input, opts)
+		if errors.Is(err, errBodyTooLarge) {
+			return importTooLarge(c, bodyLimit)
+		}
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		fullReport := c.Query("report") == "full"
+		report := models.ImportReport{
+			DryRun: c.QueryBool("dry_run", false),
+			Rows:   make([]models.ImportRowResult, 0),
+		}
+
+		addRow := func(r models.ImportRowResult) {
+			if r.Status == models.ImportRowInvalid || r.Status == models.ImportRowFailed {
+				report.Failed++
+			}
+			if fullReport || r.Status == models.ImportRowInvalid || r.Status == models.ImportRowFailed {
+				report.Rows = append(report.Rows, r)
+			}
+		}
+
+		repo := repositories.NewSubscriptionRepository(db)
+		ctx := auditContext(c)
+
+		lines := make([]int, 0, chunkSize)
+		ops := make([]models.BatchOperation, 0, chunkSize)
+		flush := func() {
+			for _, r := range storeImportChunk(ctx, repo, lines, ops) {
+				if r.Status == models.ImportRowCreated {
+					report.Created++
+				}
+				addRow(r)
+			}
+			lines, ops = lines[:0], ops[:0]
+		}
+
+		for {
+			row, err := reader.Next()
+			if err == io.EOF {
+				break
+			}
+			if errors.Is(err, errBodyTooLarge) {
+				return importTooLarge(c, bodyLimit)
+			}
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Failed to read CSV: " + err.Error(),
+				})
+			}
+
+			report.Total++
+
+			if row.Err == nil {
+				row.Err = row.Subscription.Validate()
+			}
+			if row.Err != nil {
+				addRow(models.ImportRowResult{
+					Line:   row.Line,
+					Status: models.ImportRowInvalid,
+					Errors: errorList(row.Err),
+				})
+				continue
+			}
+
+			report.Valid++
+
+			if report.DryRun {
+				addRow(models.ImportRowResult{Line: row.Line, Status: models.ImportRowValid})
+				continue
+			}
+
+			body := row.Subscription
+			lines = append(lines, row.Line)
+			ops = append(ops, models.BatchOperation{Op: models.BatchOpCreate, Body: &body})
+			if len(ops) == chunkSize {
+				flush()
+			}
+		}
+
+		if len(ops) > 0 {
+			flush()
+		}
+
+		return c.JSON(report)
+	}
+}
+
+// storeImportChunk inserts a chunk in one transaction. If the database rejects
+// it, the rows are retried one by one so the report points at the bad lines.
+func storeImportChunk(
+	ctx context.Context,
+	repo *repositories.SubscriptionRepository,
+	lines []int,
+	ops []models.BatchOperation,
+) []models.ImportRowResult {
+
+	outcomes, err := repo.ApplyBatchAtomic(ctx, ops)
+	if err != nil {
+		logging.FromContext(ctx).Error("import failed", "error", err)
+		outcomes = repo.ApplyBatchPartial(ctx, ops)
+	}
+
+	results := make([]models.ImportRowResult, len(outcomes))
+	for i, out := range outcomes {
+		if out.Err != nil {
+			results[i] = models.ImportRowResult{
+				Line:   lines[i],
+				Status: models.ImportRowFailed,
+				Errors: errorList(out.Err),
+			}
+			continue
+		}
+
+		results[i] = models.ImportRowResult{
+			Line:   lines[i],
+			Status: models.ImportRowCreated,
+			ID:     out.Subscription.ID,
+		}
+	}
+	return results
+}
+
+func importOptions(c *fiber.Ctx) (importer.Options, error) {
+	opts := importer.Options{Delimiter: ','}
+
+	switch d := c.Query("delimiter"); {
+	case d == "":
+	case d == "tab" || d == `\t`:
+		opts.Delimiter = '\t'
+	case utf8.RuneCountInString(d) == 1:
+		opts.Delimiter, _ = utf8.DecodeRuneInString(d)
+	default:
+		return opts, errors.New("delimiter must be a single character")
+	}
+
+	layout, err := importer.ParseDateFormat(c.Query("date_format", "YYYY-MM-DD"))
+	if err != nil {
+		return opts, err
+	}
+	opts.DateLayout = layout
+
+	opts.Columns, err = importer.ParseColumns(c.Query("columns"))
+	return opts, err
+}
+
+// importInput returns the CSV stream. Raw bodies and multipart files are
+// read as they arrive instead of being buffered whole. Reading fails with
+// errBodyTooLarge past limit, which also covers chunked bodies whose size
+// middleware.BodyLimit cannot know up front.
+func importInput(c *fiber.Ctx, limit int) (io.ReadCloser, error) {
+	var body io.Reader = bytes.NewReader(c.Body())
+	if stream := c.Context().RequestBodyStream(); stream != nil {
+		body = stream
+	}
+	if limit > 0 {
+		body = &limitedBody{r: body, left: int64(limit)}
+	}
+
+	if !strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+		return io.NopCloser(body), nil
+	}
+
+	parts := multipart.NewReader(body, string(c.Request().Header.MultipartFormBoundary()))
+	for {
+		part, err := parts.NextPart()
+		if errors.Is(err, errBodyTooLarge) {
+			return nil, err
+		}
+		if err != nil {
+			return nil, errors.New(`multipart upload must contain a "file" field`)
+		}
+		if part.FormName() == "file" {
+			return part, nil
+		}
+	}
+}
+
+var errBodyTooLarge = errors.New("request body is too large")
+
+// limitedBody fails with errBodyTooLarge once more than left bytes are read
+type limitedBody struct {
+	r    io.Reader
+	left int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.left < 0 {
+		return 0, errBodyTooLarge
+	}
+	if int64(len(p)) > b.left+1 {
+		p = p[:b.left+1]
+	}
+
+	n, err := b.r.Read(p)
+	b.left -= int64(n)
+	if b.left < 0 {
+		return 0, errBodyTooLarge
+	}
+	return n, err
+}
+
+func importTooLarge(c *fiber.Ctx, limit int) error {
+	// The rest of the body is never read, the connection can not be reused
+	c.Context().SetConnectionClose()
+
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"status":  "error",
+		"message": fmt.Sprintf("request body must not exceed %d bytes", limit),
+	})
+}
+
+func errorList(err error) []string {
+	var verrs models.ValidationErrors
+	if errors.As(err, &verrs) {
+		return verrs
+	}
+	return []string{err.Error()}
+}
@@ -0,0 +1,66 @@
+package handler
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/internal/health"
+)
+
+// Livez godoc
+// @Summary      Liveness probe
+// @Description  Fails when a background worker is stuck and the process should be restarted. Does not depend on the database. Add ?verbose to list every check.
+// @Tags         HealthCheck
+// @Produce      json
+// @Param        verbose  query     bool  false  "List every check"
+// @Success      200      {object}  health.Report
+// @Failure      503      {object}  health.Report
+// @Router       /livez [get]
+func Livez(registry *health.Registry) fiber.Handler {
+	return probe(registry, health.Liveness)
+}
+
+// Readyz godoc
+// @Summary      Readiness probe
+// @Description  Fails while the instance should not receive traffic: the database is unreachable, migrations are missing or the server is shutting down. A disconnected change stream only makes the status degraded. Add ?verbose to list every check.
+// @Tags         HealthCheck
+// @Produce      json
+// @Param        verbose  query     bool  false  "List every check"
+// @Success      200      {object}  health.Report
+// @Failure      503      {object}  health.Report
+// @Router       /readyz [get]
+func Readyz(registry *health.Registry) fiber.Handler {
+	return probe(registry, health.Readiness)
+}
+
+// Startupz godoc
+// @Summary      Startup probe
+// @Description  Fails until the database is reachable and migrated, then keeps passing. Add ?verbose to list every check.
+// @Tags         HealthCheck
+// @Produce      json
+// @Param        verbose  query     bool  false  "List every check"
+// @Success      200      {object}  health.Report
+// @Failure      503      {object}  health.Report
+// @Router       /startupz [get]
+func Startupz(registry *health.Registry) fiber.Handler {
+	return probe(registry, health.Startup)
+}
+
+// probe answers 200, also when degraded, or 503. Checks are listed unless
+// all pass, or when verbose is set, with or without a value as in ?verbose.
+func probe(registry *health.Registry, p health.Probe) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		report := registry.Run(c.UserContext(), p)
+
+		verbose := c.Context().QueryArgs().Has("verbose") && c.Query("verbose") != "false"
+		if report.Status == health.StatusOK && !verbose {
+			report.Checks = nil
+		}
+
+		status := fiber.StatusOK
+		if !report.OK() {
+			status = fiber.StatusServiceUnavailable
+		}
+		c.Set(fiber.HeaderCacheControl, "no-store")
+		return c.Status(status).JSON(report)
+	}
+}
@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+	"github.com/nurkenspashev92/emob/internal/stream"
+)
+
+const (
+	streamKeepAlive  = 15 * time.Second
+	streamReplayPage = 500
+)
+
+// StreamSubscriptionChanges godoc
+// @Summary      Stream subscription changes
+// @Description  Server-Sent Events stream of created, updated, deleted and restored subscriptions. The event id is the audit event id; reconnect with Last-Event-ID (or last_event_id) to receive the changes missed in between.
+// @Tags         Subscriptions
+// @Produce      text/event-stream
+// @Param        user_id        query     string  false  "Only changes of this user"
+// @Param        service_name   query     string  false  "Only changes of this service, case-insensitive"
+// @Param        last_event_id  query     int     false  "Resume after this event id"
+// @Param        Last-Event-ID  header    int     false  "Resume after this event id"
+// @Success      200            {string}  string  "event stream"
+// @Failure      400            {object}  map[string]string
+// @Router       /api/v1/subscriptions/stream [get]
+func StreamSubscriptionChanges(db *pgxpool.Pool, hub *stream.Hub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		filter := models.SubscriptionChangeFilter{
+			UserID:      c.Query("user_id"),
+			ServiceName: c.Query("service_name"),
+		}
+
+		var lastID int64
+		if raw := c.Get("Last-Event-ID", c.Query("last_event_id")); raw != "" {
+			id, err := strconv.ParseInt(raw, 10, 64)
+			if err != nil || id < 0 {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Last-Event-ID must be a non-negative integer",
+				})
+			}
+			lastID = id
+		}
+
+		c.Set(fiber.HeaderContentType, "text/event-stream")
+		c.Set(fiber.HeaderCacheControl, "no-cache")
+		c.Set(fiber.HeaderConnection, "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		// Subscribe before replaying so nothing committed in between is missed
+		client := hub.Subscribe(filter)
+		repo := repositories.NewAuditRepository(db)
+		ctx := c.UserContext()
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			defer hub.Unsubscribe(client)
+
+			fmt.Fprintf(w, "retry: 3000\n\n")
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			replayed, err := replayChanges(ctx, repo, w, filter, lastID)
+			if err != nil {
+				logging.FromContext(ctx).Error("change stream replay failed", "error", err)
+				return
+			}
+
+			ticker := time.NewTicker(streamKeepAlive)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case change, ok := <-client.Changes():
+					if !ok {
+						return
+					}
+					if change.ID <= lastID || replayed[change.ID] {
+						continue
+					}
+					if err := writeChange(w, change); err != nil {
+						return
+					}
+				case <-ticker.C:
+					fmt.Fprintf(w, ": keepalive\n\n")
+				}
+
+				// A failed flush means the client went away
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+
+		return nil
+	}
+}
+
+// replayChanges sends every matching change after lastID and returns the ids
+// it sent, so the same changes arriving live can be skipped.
+func replayChanges(
+	ctx context.Context,
+	repo *repositories.AuditRepository,
+	w *bufio.Writer,
+	filter models.SubscriptionChangeFilter,
+	lastID int64,
+) (map[int64]bool, error) {
+
+	replayed := make(map[int64]bool)
+	if lastID == 0 {
+		return replayed, nil
+	}
+
+	for {
+		changes, err := repo.GetSubscriptionChangesAfter(ctx, lastID, streamReplayPage)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, change := range changes {
+			lastID = change.ID
+			replayed[change.ID] = true
+			if !filter.Match(change) {
+				continue
+			}
+			if err := writeChange(w, change); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := w.Flush(); err != nil {
+			return nil, err
+		}
+		if len(changes) < streamReplayPage {
+			return replayed, nil
+		}
+	}
+}
+
+func writeChange(w *bufio.Writer, change models.SubscriptionChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+	return err
+}
@@ -1,11 +1,14 @@
 package handler
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/logging"
 	"github.com/nurkenspashev92/emob/internal/models"
 	"github.com/nurkenspashev92/emob/internal/repositories"
 )
@@ -18,22 +21,29 @@ import (
 // @Produce      json
 // @Param        limit   query     int  false  "Limit"   default(10)
 // @Param        offset query     int  false  "Offset"  default(0)
+// @Param        include_deleted query bool false "Include soft-deleted subscriptions, needs an admin API key"  default(false)
 // @Success      200     {array}   models.Subscription
+// @Failure      401     {object}  interface{}
+// @Failure      403     {object}  interface{}
 // @Failure      500     {object}  interface{}
 // @Router       /api/v1/subscriptions [get]
 func GetSubscriptions(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		limit := c.QueryInt("limit", 10)
-		offset := c.QueryInt("offset", 0)
+		deleted, ok := includeDeleted(c)
+		if !ok {
+			return nil
+		}
+
+		filter := models.SubscriptionFilter{
+			Limit:          c.QueryInt("limit", 10),
+			Offset:         c.QueryInt("offset", 0),
+			IncludeDeleted: deleted,
+		}
 
 		repo := repositories.NewSubscriptionRepository(db)
-		subscriptions, err := repo.GetAllSubscriptions(
-			c.Context(),
-			limit,
-			offset,
-		)
+		subscriptions, err := repo.GetAllSubscriptions(c.UserContext(), filter)
 		if err != nil {
-			fmt.Println("Error:", err)
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
 			return c.Status(500).JSON(fiber.Map{
 				"status":  "error",
 				"message": err.Error(),
@@ -51,8 +61,10 @@ func GetSubscriptions(db *pgxpool.Pool) fiber.Handler {
 // @Accept       json
 // @Produce      json
 // @Param        body  body      models.CreateSubscription  true  "Subscription body"
+// @Param        Idempotency-Key header string false "Unique key to safely retry the request"
 // @Success      201   {object}  models.Subscription
 // @Failure      400   {object}  map[string]string
+// @Failure      409   {object}  map[string]string
 // @Failure      500   {object}  map[string]string
 // @Router       /api/v1/subscriptions [post]
 func CreateSubscription(db *pgxpool.Pool) fiber.Handler {
@@ -60,24 +72,32 @@ func CreateSubscription(db *pgxpool.Pool) fiber.Handler {
 		var body models.CreateSubscription
 
 		if err := c.BodyParser(&body); err != nil {
-			fmt.Println("Error decoding JSON:", err)
+			logging.FromContext(c.UserContext()).Debug("invalid request body", "error", err)
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Invalid request body",
 			})
 		}
 
+		if err := body.Validate(); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
 		repo := repositories.NewSubscriptionRepository(db)
 
-		subscription, err := repo.CreateSubscriptions(c.Context(), body)
+		subscription, err := repo.CreateSubscriptions(auditContext(c), body)
 		if err != nil {
-			fmt.Println("Error:", err)
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"status":  "error",
 				"message": err.Error(),
 			})
 		}
 
+		c.Set(fiber.HeaderETag, etag(subscription.Version))
 		return c.Status(fiber.StatusCreated).JSON(subscription)
 	}
 }
@@ -89,23 +109,40 @@ func CreateSubscription(db *pgxpool.Pool) fiber.Handler {
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Subscription ID"
+// @Param        include_deleted query bool false "Include soft-deleted subscriptions, needs an admin API key"  default(false)
+// @Param        If-None-Match header string false "ETag from a previous response"
 // @Success      200  {object}  models.Subscription
+// @Success      304  "Not Modified"
+// @Failure      401  {object}  interface{}
+// @Failure      403  {object}  interface{}
 // @Failure      404  {object}  interface{}
 // @Router       /api/v1/subscriptions/{id} [get]
 func GetSubscription(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		id := c.Params("id")
+		id, ok := subscriptionID(c)
+		if !ok {
+			return nil
+		}
+		deleted, ok := includeDeleted(c)
+		if !ok {
+			return nil
+		}
 		repo := repositories.NewSubscriptionRepository(db)
 
-		sub, err := repo.GetSubscriptionByID(c.Context(), id)
+		sub, err := repo.GetSubscriptionByID(c.UserContext(), id, deleted)
 		if err != nil {
-			fmt.Println("Error:", err)
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
 			return c.Status(404).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Subscription not found",
 			})
 		}
 
+		c.Set(fiber.HeaderETag, etag(sub.Version))
+		if ifNoneMatch(c, sub.Version) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+
 		return c.JSON(sub)
 	}
 }
@@ -118,64 +155,219 @@ func GetSubscription(db *pgxpool.Pool) fiber.Handler {
 // @Produce      json
 // @Param        id    path      string  true  "Subscription ID"
 // @Param        body  body      models.CreateSubscription  true  "Subscription body"
+// @Param        If-Match header string false "ETag of the version being replaced"
 // @Success      200   {object}  models.Subscription
 // @Failure      400   {object}  interface{}
+// @Failure      404   {object}  interface{}
+// @Failure      412   {object}  interface{}
+// @Failure      428   {object}  interface{}
 // @Failure      500   {object}  interface{}
 // @Router       /api/v1/subscriptions/{id} [put]
 func UpdateSubscription(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		id := c.Params("id")
+		id, ok := subscriptionID(c)
+		if !ok {
+			return nil
+		}
 		var body models.CreateSubscription
 		if err := c.BodyParser(&body); err != nil {
-			fmt.Println("Error decoding JSON:", err)
+			logging.FromContext(c.UserContext()).Debug("invalid request body", "error", err)
 			return c.Status(400).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Invalid request body",
 			})
 		}
 
+		if err := body.Validate(); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return preconditionFailed(c)
+		}
+
+		repo := repositories.NewSubscriptionRepository(db)
+		sub, err := repo.UpdateSubscription(auditContext(c), id, body, versions)
+		if errors.Is(err, repositories.ErrSubscriptionNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Subscription not found",
+			})
+		}
+		if errors.Is(err, repositories.ErrVersionMismatch) {
+			return preconditionFailed(c)
+		}
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		c.Set(fiber.HeaderETag, etag(sub.Version))
+		return c.JSON(sub)
+	}
+}
+
+// PatchSubscription godoc
+// @Summary      Partially update subscription
+// @Description  Updates only the provided fields of a subscription. The merged subscription is validated like UpdateSubscription.
+// @Tags         Subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string  true  "Subscription ID"
+// @Param        body  body      models.PatchSubscription  true  "Fields to change"
+// @Param        If-Match header string false "ETag of the version being changed"
+// @Success      200   {object}  models.Subscription
+// @Failure      400   {object}  interface{}
+// @Failure      404   {object}  interface{}
+// @Failure      412   {object}  interface{}
+// @Failure      428   {object}  interface{}
+// @Failure      500   {object}  interface{}
+// @Router       /api/v1/subscriptions/{id} [patch]
+func PatchSubscription(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := subscriptionID(c)
+		if !ok {
+			return nil
+		}
+		var body models.PatchSubscription
+		if err := c.BodyParser(&body); err != nil {
+			logging.FromContext(c.UserContext()).Debug("invalid request body", "error", err)
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid request body",
+			})
+		}
+
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return preconditionFailed(c)
+		}
+
 		repo := repositories.NewSubscriptionRepository(db)
-		sub, err := repo.UpdateSubscription(c.Context(), id, body)
+		sub, err := repo.PatchSubscription(auditContext(c), id, body, versions)
+		if errors.Is(err, repositories.ErrSubscriptionNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Subscription not found",
+			})
+		}
+		if errors.Is(err, repositories.ErrInvalidSubscription) {
+			return c.Status(400).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+		if errors.Is(err, repositories.ErrVersionMismatch) {
+			return preconditionFailed(c)
+		}
 		if err != nil {
-			fmt.Println("Error:", err)
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
 			return c.Status(500).JSON(fiber.Map{
 				"status":  "error",
 				"message": err.Error(),
 			})
 		}
 
+		c.Set(fiber.HeaderETag, etag(sub.Version))
 		return c.JSON(sub)
 	}
 }
 
 // DeleteSubscription godoc
 // @Summary      Delete subscription
-// @Description  Deletes subscription by ID
+// @Description  Soft-deletes subscription by ID, it can be restored until the retention purge
 // @Tags         Subscriptions
 // @Accept       json
 // @Produce      json
 // @Param        id   path      string  true  "Subscription ID"
+// @Param        If-Match header string false "ETag of the version being deleted"
 // @Success      204  "No Content"
 // @Failure      404  {object}  interface{}
+// @Failure      412  {object}  interface{}
+// @Failure      428  {object}  interface{}
+// @Failure      500  {object}  interface{}
 // @Router       /api/v1/subscriptions/{id} [delete]
 func DeleteSubscription(db *pgxpool.Pool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		id := c.Params("id")
+		id, ok := subscriptionID(c)
+		if !ok {
+			return nil
+		}
 		repo := repositories.NewSubscriptionRepository(db)
 
-		err := repo.DeleteSubscription(c.Context(), id)
-		if err != nil {
-			fmt.Println("Error:", err)
+		versions, ok := ifMatchVersions(c)
+		if !ok {
+			return preconditionFailed(c)
+		}
+
+		err := repo.DeleteSubscription(auditContext(c), id, versions)
+		if errors.Is(err, repositories.ErrSubscriptionNotFound) {
 			return c.Status(404).JSON(fiber.Map{
 				"status":  "error",
 				"message": "Subscription not found",
 			})
 		}
+		if errors.Is(err, repositories.ErrVersionMismatch) {
+			return preconditionFailed(c)
+		}
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
 
 		return c.SendStatus(fiber.StatusNoContent)
 	}
 }
 
+// RestoreSubscription godoc
+// @Summary      Restore subscription
+// @Description  Restores a soft-deleted subscription
+// @Tags         Subscriptions
+// @Accept       json
+// @Produce      json
+// @Param        id   path      string  true  "Subscription ID"
+// @Success      200  {object}  models.Subscription
+// @Failure      404  {object}  interface{}
+// @Failure      500  {object}  interface{}
+// @Router       /api/v1/subscriptions/{id}/restore [post]
+func RestoreSubscription(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := subscriptionID(c)
+		if !ok {
+			return nil
+		}
+		repo := repositories.NewSubscriptionRepository(db)
+
+		sub, err := repo.RestoreSubscription(auditContext(c), id)
+		if errors.Is(err, repositories.ErrSubscriptionNotFound) {
+			return c.Status(404).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Deleted subscription not found",
+			})
+		}
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(500).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		c.Set(fiber.HeaderETag, etag(sub.Version))
+		return c.JSON(sub)
+	}
+}
+
 // GetSubscriptionsTotal godoc
 // @Summary      Get total subscriptions cost
 // @Description  Returns total cost of subscriptions for selected period with optional filters
@@ -188,6 +380,7 @@ func DeleteSubscription(db *pgxpool.Pool) fiber.Handler {
 // @Param        service_name query     string  false  "Service name"
 // @Success      200          {object}  map[string]float64
 // @Failure      400          {object}  map[string]string
+// @Failure      429          {object}  map[string]interface{}
 // @Failure      500          {object}  map[string]string
 // @Router       /api/v1/subscriptions/total [get]
 func GetSubscriptionsTotal(db *pgxpool.Pool) fiber.Handler {
@@ -208,7 +401,7 @@ func GetSubscriptionsTotal(db *pgxpool.Pool) fiber.Handler {
 		repo := repositories.NewSubscriptionRepository(db)
 
 		total, err := repo.GetTotalSubscriptionsCost(
-			c.Context(),
+			c.UserContext(),
 			dateFrom,
 			dateTo,
 			userID,
@@ -226,3 +419,42 @@ func GetSubscriptionsTotal(db *pgxpool.Pool) fiber.Handler {
 		})
 	}
 }
+
+// subscriptionID reads the :id param, answering 404 for values that cannot be an ID
+func subscriptionID(c *fiber.Ctx) (string, bool) {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Subscription not found",
+		})
+		return "", false
+	}
+	return id, true
+}
+
+// includeDeleted reads the include_deleted query flag. Soft-deleted rows are
+// only shown to admins, anyone else asking for them is refused.
+func includeDeleted(c *fiber.Ctx) (bool, bool) {
+	if !c.QueryBool("include_deleted", false) {
+		return false, true
+	}
+
+	p, ok := auth.FromContext(c.UserContext())
+	if !ok {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+			"status":  "error",
+			"message": "An API key is required to include deleted subscriptions",
+		})
+		return false, false
+	}
+	if !p.IsAdmin() {
+		c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Only admins may include deleted subscriptions",
+		})
+		return false, false
+	}
+	return true, true
+}
@@ -0,0 +1,360 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+	"github.com/nurkenspashev92/emob/internal/webhooks"
+)
+
+// CreateWebhook godoc
+// @Summary      Register webhook
+// @Description  Subscribes a URL to events. Requests are signed with HMAC-SHA256 in the X-Emob-Signature header; the secret is generated when omitted and only returned here. Needs an admin API key.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        body  body      models.CreateWebhook  true  "Webhook"
+// @Success      201   {object}  models.Webhook
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/webhooks [post]
+func CreateWebhook(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		body, ok := parseWebhookBody(c)
+		if !ok {
+			return nil
+		}
+
+		if body.Secret == "" {
+			secret, err := webhooks.GenerateSecret()
+			if err != nil {
+				logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Failed to generate secret",
+				})
+			}
+			body.Secret = secret
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		webhook, err := repo.CreateWebhook(c.UserContext(), body)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(webhook)
+	}
+}
+
+// GetWebhooks godoc
+// @Summary      List webhooks
+// @Description  Needs an admin API key.
+// @Tags         Webhooks
+// @Produce      json
+// @Success      200  {array}   models.Webhook
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/webhooks [get]
+func GetWebhooks(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		repo := repositories.NewWebhookRepository(db)
+
+		list, err := repo.GetWebhooks(c.UserContext())
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		}
+
+		return c.JSON(list)
+	}
+}
+
+// GetWebhook godoc
+// @Summary      Get webhook
+// @Description  Needs an admin API key.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id   path      string  true  "Webhook ID"
+// @Success      200  {object}  models.Webhook
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Router       /api/v1/webhooks/{id} [get]
+func GetWebhook(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := webhookID(c)
+		if !ok {
+			return nil
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		webhook, err := repo.GetWebhookByID(c.UserContext(), id)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.JSON(webhook)
+	}
+}
+
+// UpdateWebhook godoc
+// @Summary      Update webhook
+// @Description  Replaces url, events and active. A non-empty secret rotates the signing secret. Needs an admin API key.
+// @Tags         Webhooks
+// @Accept       json
+// @Produce      json
+// @Param        id    path      string                true  "Webhook ID"
+// @Param        body  body      models.CreateWebhook  true  "Webhook"
+// @Success      200   {object}  models.Webhook
+// @Failure      400   {object}  map[string]string
+// @Failure      401   {object}  map[string]string
+// @Failure      403   {object}  map[string]string
+// @Failure      404   {object}  map[string]string
+// @Failure      500   {object}  map[string]string
+// @Router       /api/v1/webhooks/{id} [put]
+func UpdateWebhook(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := webhookID(c)
+		if !ok {
+			return nil
+		}
+
+		body, ok := parseWebhookBody(c)
+		if !ok {
+			return nil
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		webhook, err := repo.UpdateWebhook(c.UserContext(), id, body)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.JSON(webhook)
+	}
+}
+
+// DeleteWebhook godoc
+// @Summary      Delete webhook
+// @Description  Removes the webhook and its delivery log. Needs an admin API key.
+// @Tags         Webhooks
+// @Param        id   path  string  true  "Webhook ID"
+// @Success      204  "No Content"
+// @Failure      401  {object}  map[string]string
+// @Failure      403  {object}  map[string]string
+// @Failure      404  {object}  map[string]string
+// @Failure      500  {object}  map[string]string
+// @Router       /api/v1/webhooks/{id} [delete]
+func DeleteWebhook(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := webhookID(c)
+		if !ok {
+			return nil
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		if err := repo.DeleteWebhook(c.UserContext(), id); err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// GetWebhookDeliveries godoc
+// @Summary      List webhook deliveries
+// @Description  Returns the delivery log of a webhook, newest first. Needs an admin API key.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id      path      string  true   "Webhook ID"
+// @Param        status  query     string  false  "Delivery status"  Enums(pending, succeeded, dead)
+// @Param        limit   query     int     false  "Limit"   default(50)
+// @Param        offset  query     int     false  "Offset"  default(0)
+// @Success      200     {array}   models.WebhookDelivery
+// @Failure      400     {object}  map[string]string
+// @Failure      401     {object}  map[string]string
+// @Failure      403     {object}  map[string]string
+// @Failure      404     {object}  map[string]string
+// @Failure      500     {object}  map[string]string
+// @Router       /api/v1/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, ok := webhookID(c)
+		if !ok {
+			return nil
+		}
+
+		status := c.Query("status")
+		switch status {
+		case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+		default:
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "status must be pending, succeeded or dead",
+			})
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		if _, err := repo.GetWebhookByID(c.UserContext(), id); err != nil {
+			return webhookError(c, err)
+		}
+
+		deliveries, err := repo.GetDeliveries(c.UserContext(), id, status, c.QueryInt("limit", 50), c.QueryInt("offset", 0))
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.JSON(deliveries)
+	}
+}
+
+// GetWebhookDelivery godoc
+// @Summary      Get webhook delivery
+// @Description  Returns a delivery with the log of every attempt. Needs an admin API key.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id           path      string  true  "Webhook ID"
+// @Param        delivery_id  path      string  true  "Delivery ID"
+// @Success      200          {object}  models.WebhookDelivery
+// @Failure      401          {object}  map[string]string
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /api/v1/webhooks/{id}/deliveries/{delivery_id} [get]
+func GetWebhookDelivery(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, deliveryID, ok := deliveryIDs(c)
+		if !ok {
+			return nil
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		delivery, err := repo.GetDelivery(c.UserContext(), id, deliveryID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.JSON(delivery)
+	}
+}
+
+// RedeliverWebhookDelivery godoc
+// @Summary      Redeliver webhook event
+// @Description  Queues the delivery again with a fresh retry budget, including succeeded and dead ones. Needs an admin API key.
+// @Tags         Webhooks
+// @Produce      json
+// @Param        id           path      string  true  "Webhook ID"
+// @Param        delivery_id  path      string  true  "Delivery ID"
+// @Success      202          {object}  models.WebhookDelivery
+// @Failure      401          {object}  map[string]string
+// @Failure      403          {object}  map[string]string
+// @Failure      404          {object}  map[string]string
+// @Failure      500          {object}  map[string]string
+// @Router       /api/v1/webhooks/{id}/deliveries/{delivery_id}/redeliver [post]
+func RedeliverWebhookDelivery(db *pgxpool.Pool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id, deliveryID, ok := deliveryIDs(c)
+		if !ok {
+			return nil
+		}
+
+		repo := repositories.NewWebhookRepository(db)
+		delivery, err := repo.Redeliver(c.UserContext(), id, deliveryID)
+		if err != nil {
+			return webhookError(c, err)
+		}
+
+		return c.Status(fiber.StatusAccepted).JSON(delivery)
+	}
+}
+
+// parseWebhookBody decodes and validates the body, writing a 400 response
+// when it is unusable.
+func parseWebhookBody(c *fiber.Ctx) (models.CreateWebhook, bool) {
+	var body models.CreateWebhook
+	if err := c.BodyParser(&body); err != nil {
+		logging.FromContext(c.UserContext()).Debug("invalid request body", "error", err)
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Invalid request body",
+		})
+		return body, false
+	}
+
+	if err := body.Validate(); err != nil {
+		c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return body, false
+	}
+
+	if body.Active == nil {
+		active := true
+		body.Active = &active
+	}
+
+	return body, true
+}
+
+// webhookID reads the :id param, answering 404 for values that cannot be an ID
+func webhookID(c *fiber.Ctx) (string, bool) {
+	id := c.Params("id")
+	if _, err := uuid.Parse(id); err != nil {
+		webhookError(c, repositories.ErrWebhookNotFound)
+		return "", false
+	}
+	return id, true
+}
+
+func deliveryIDs(c *fiber.Ctx) (string, string, bool) {
+	id, ok := webhookID(c)
+	if !ok {
+		return "", "", false
+	}
+
+	deliveryID := c.Params("delivery_id")
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		webhookError(c, repositories.ErrDeliveryNotFound)
+		return "", "", false
+	}
+	return id, deliveryID, true
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrWebhookNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Webhook not found",
+		})
+	case errors.Is(err, repositories.ErrDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"status":  "error",
+			"message": "Delivery not found",
+		})
+	}
+
+	logging.FromContext(c.UserContext()).Error("request failed", "error", err)
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"status":  "error",
+		"message": err.Error(),
+	})
+}
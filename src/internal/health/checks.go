@@ -0,0 +1,53 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/pkg/store"
+)
+
+// Database checks that a pooled connection answers
+func Database(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return db.Ping(ctx)
+	}
+}
+
+// Migrations checks that the schema has every embedded migration applied
+// and none was left half applied
+func Migrations(db *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return store.CheckSchema(ctx, db)
+	}
+}
+
+// Heartbeat is beaten by a background worker on every loop. Its check fails
+// when the worker has not come round for longer than maxAge, which means it
+// is stuck.
+type Heartbeat struct {
+	maxAge time.Duration
+	last   atomic.Int64
+}
+
+func NewHeartbeat(maxAge time.Duration) *Heartbeat {
+	h := &Heartbeat{maxAge: maxAge}
+	h.Beat()
+	return h
+}
+
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func (h *Heartbeat) Check(context.Context) error {
+	age := time.Since(time.Unix(0, h.last.Load()))
+	if age > h.maxAge {
+		return fmt.Errorf("no heartbeat for %s", age.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Package health runs the checks behind the liveness, readiness and startup
+// probes. Checks are registered once at startup for one or more probes and
+// run concurrently, each under its own timeout.
+package health
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Probe int
+
+const (
+	// Liveness fails only when the process is stuck and should be restarted
+	Liveness Probe = iota
+	// Readiness fails while the instance should get no traffic
+	Readiness
+	// Startup fails until the instance has finished starting
+	Startup
+)
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusDegraded is an optional check failing, the probe still passes
+	StatusDegraded = "degraded"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// Check returns nil when the dependency it checks is healthy
+type Check func(ctx context.Context) error
+
+type Result struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// OK reports whether the probe passes, which it does while degraded
+func (r Report) OK() bool {
+	return r.Status != StatusFail
+}
+
+type registered struct {
+	name    string
+	check   Check
+	timeout time.Duration
+	probes  []Probe
+	// optional checks only degrade the report when they fail
+	optional bool
+}
+
+type Registry struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []registered
+
+	started      atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+// NewRegistry returns a registry whose checks time out after timeout unless
+// they are registered with their own.
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check to the given probes
+func (r *Registry) Register(name string, check Check, probes ...Probe) {
+	r.RegisterWithTimeout(name, r.timeout, check, probes...)
+}
+
+func (r *Registry) RegisterWithTimeout(name string, timeout time.Duration, check Check, probes ...Probe) {
+	r.add(registered{name: name, check: check, timeout: timeout, probes: probes})
+}
+
+// RegisterOptional adds a check for a feature the instance can serve
+// without. Its failure marks the report degraded but passes the probe.
+func (r *Registry) RegisterOptional(name string, check Check, probes ...Probe) {
+	r.add(registered{name: name, check: check, timeout: r.timeout, probes: probes, optional: true})
+}
+
+func (r *Registry) add(c registered) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checks = append(r.checks, c)
+}
+
+// SetShuttingDown makes readiness fail so load balancers stop sending new
+// requests before the server closes.
+func (r *Registry) SetShuttingDown() {
+	r.shuttingDown.Store(true)
+}
+
+// Run executes the checks of a probe. Once the startup probe has passed it
+// keeps passing without running its checks again.
+func (r *Registry) Run(ctx context.Context, probe Probe) Report {
+	if probe == Startup && r.started.Load() {
+		return Report{Status: StatusOK}
+	}
+
+	r.mu.RLock()
+	var checks []registered
+	for _, c := range r.checks {
+		for _, p := range c.probes {
+			if p == probe {
+				checks = append(checks, c)
+				break
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}()
+	}
+	wg.Wait()
+
+	if probe == Readiness && r.shuttingDown.Load() {
+		results = append(results, Result{Name: "shutdown", Status: StatusFail, Error: errShuttingDown.Error()})
+	}
+
+	report := Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		switch {
+		case res.Status == StatusFail:
+			report.Status = StatusFail
+		case res.Status == StatusDegraded && report.Status == StatusOK:
+			report.Status = StatusDegraded
+		}
+	}
+
+	if probe == Startup && report.OK() {
+		r.started.Store(true)
+	}
+
+	return report
+}
+
+func run(ctx context.Context, c registered) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.check(ctx)
+	}()
+
+	// A check that ignores its context still cannot hold the probe up
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Name:       c.name,
+		Status:     StatusOK,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusFail
+		if c.optional {
+			res.Status = StatusDegraded
+		}
+		res.Error = err.Error()
+	}
+	return res
+}
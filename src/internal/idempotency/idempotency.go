@@ -0,0 +1,36 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+// Record is a stored response for an idempotency key. A zero StatusCode
+// means the original request is still being processed.
+type Record struct {
+	Key         string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+func (r *Record) InProgress() bool {
+	return r.StatusCode == 0
+}
+
+// Store keeps idempotency records. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Begin claims key for a new request for the lease. If the key is
+	// already known the existing record is returned and started is false.
+	// A key still in progress after its lease is taken over, its request is
+	// assumed to have died.
+	Begin(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (existing *Record, started bool, err error)
+	// Complete stores the response for a key claimed with Begin
+	Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error
+	// Release forgets a claimed key so the request can be retried
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes records past their TTL
+	DeleteExpired(ctx context.Context) (int, error)
+}
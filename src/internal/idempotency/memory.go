@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	record      Record
+	expiresAt   time.Time
+	lockedUntil time.Time
+}
+
+// MemoryStore keeps records in process memory, suitable for a single instance
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: make(map[string]*memoryEntry)}
+}
+
+func (s *MemoryStore) Begin(_ context.Context, key, requestHash string, ttl, lease time.Duration) (*Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := s.entries[key]; ok && now.Before(e.expiresAt) {
+		if !e.record.InProgress() || now.Before(e.lockedUntil) {
+			rec := e.record
+			return &rec, false, nil
+		}
+	}
+
+	s.entries[key] = &memoryEntry{
+		record:      Record{Key: key, RequestHash: requestHash},
+		expiresAt:   now.Add(ttl),
+		lockedUntil: now.Add(lease),
+	}
+	return nil, true, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, statusCode int, contentType string, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.record.StatusCode = statusCode
+		e.record.ContentType = contentType
+		e.record.Body = append([]byte(nil), body...)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(_ context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for key, e := range s.entries {
+		if !now.Before(e.expiresAt) {
+			delete(s.entries, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
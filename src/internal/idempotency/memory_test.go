@@ -0,0 +1,143 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"testing/synctest"
+	"time"
+)
+
+const (
+	testTTL   = time.Hour
+	testLease = time.Minute
+)
+
+func TestMemoryStoreBegin(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup runs on a store where "key" was claimed with hash "a"
+		setup       func(s *MemoryStore)
+		hash        string
+		wantStarted bool
+		wantStatus  int
+	}{
+		{
+			name:       "in progress within the lease",
+			hash:       "a",
+			wantStatus: 0,
+		},
+		{
+			name:       "other request within the lease",
+			hash:       "b",
+			wantStatus: 0,
+		},
+		{
+			name:        "in progress after the lease is taken over",
+			setup:       func(*MemoryStore) { time.Sleep(testLease) },
+			hash:        "a",
+			wantStarted: true,
+		},
+		{
+			name: "completed is replayed after the lease",
+			setup: func(s *MemoryStore) {
+				s.Complete(context.Background(), "key", 201, "application/json", []byte(`{}`))
+				time.Sleep(testLease)
+			},
+			hash:       "a",
+			wantStatus: 201,
+		},
+		{
+			name: "completed with another request is kept",
+			setup: func(s *MemoryStore) {
+				s.Complete(context.Background(), "key", 201, "application/json", []byte(`{}`))
+			},
+			hash:       "b",
+			wantStatus: 201,
+		},
+		{
+			name: "expired record starts over",
+			setup: func(s *MemoryStore) {
+				s.Complete(context.Background(), "key", 201, "application/json", []byte(`{}`))
+				time.Sleep(testTTL)
+			},
+			hash:        "b",
+			wantStarted: true,
+		},
+		{
+			name:        "released key starts over",
+			setup:       func(s *MemoryStore) { s.Release(context.Background(), "key") },
+			hash:        "a",
+			wantStarted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synctest.Test(t, func(t *testing.T) {
+				ctx := context.Background()
+				s := NewMemoryStore()
+				if _, started, _ := s.Begin(ctx, "key", "a", testTTL, testLease); !started {
+					t.Fatal("first Begin did not start")
+				}
+				if tt.setup != nil {
+					tt.setup(s)
+				}
+
+				existing, started, err := s.Begin(ctx, "key", tt.hash, testTTL, testLease)
+				if err != nil {
+					t.Fatalf("Begin: %v", err)
+				}
+				if started != tt.wantStarted {
+					t.Fatalf("started = %v, want %v", started, tt.wantStarted)
+				}
+				if started {
+					if existing != nil {
+						t.Errorf("existing = %+v, want nil", existing)
+					}
+					return
+				}
+
+				// The middleware compares the hash itself to answer 409
+				if existing.RequestHash != "a" {
+					t.Errorf("RequestHash = %q, want the first request's", existing.RequestHash)
+				}
+				if existing.StatusCode != tt.wantStatus {
+					t.Errorf("StatusCode = %d, want %d", existing.StatusCode, tt.wantStatus)
+				}
+			})
+		})
+	}
+}
+
+func TestMemoryStoreTakeOverRenewsLease(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx := context.Background()
+		s := NewMemoryStore()
+		s.Begin(ctx, "key", "a", testTTL, testLease)
+		time.Sleep(testLease)
+
+		if _, started, _ := s.Begin(ctx, "key", "a", testTTL, testLease); !started {
+			t.Fatal("retry after the lease did not take over")
+		}
+		if _, started, _ := s.Begin(ctx, "key", "a", testTTL, testLease); started {
+			t.Fatal("a second retry took over the fresh lease")
+		}
+	})
+}
+
+func TestMemoryStoreDeleteExpired(t *testing.T) {
+	synctest.Test(t, func(t *testing.T) {
+		ctx := context.Background()
+		s := NewMemoryStore()
+		s.Begin(ctx, "old", "a", time.Minute, testLease)
+		s.Begin(ctx, "new", "a", testTTL, testLease)
+		time.Sleep(time.Minute)
+
+		if n, _ := s.DeleteExpired(ctx); n != 1 {
+			t.Fatalf("DeleteExpired = %d, want 1", n)
+		}
+		if _, ok := s.entries["new"]; !ok {
+			t.Error("the live record was deleted")
+		}
+	})
+}
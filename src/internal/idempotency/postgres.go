@@ -0,0 +1,85 @@
+package idempotency
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps records in the idempotency_keys table so retries are
+// recognised by every app instance.
+type PostgresStore struct {
+	db *pgxpool.Pool
+}
+
+func NewPostgresStore(db *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+func (s *PostgresStore) Begin(ctx context.Context, key, requestHash string, ttl, lease time.Duration) (*Record, bool, error) {
+	// An expired key, or one whose request died holding it, is claimed anew
+	query := `
+		INSERT INTO idempotency_keys (key, request_hash, expires_at, locked_until)
+		VALUES ($1, $2, NOW() + make_interval(secs => $3), NOW() + make_interval(secs => $4))
+		ON CONFLICT (key) DO UPDATE
+		SET request_hash = EXCLUDED.request_hash,
+		    status_code = 0,
+		    content_type = '',
+		    body = NULL,
+		    created_at = NOW(),
+		    expires_at = EXCLUDED.expires_at,
+		    locked_until = EXCLUDED.locked_until
+		WHERE idempotency_keys.expires_at <= NOW()
+		   OR (idempotency_keys.status_code = 0 AND idempotency_keys.locked_until <= NOW());
+	`
+
+	tag, err := s.db.Exec(ctx, query, key, requestHash, ttl.Seconds(), lease.Seconds())
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	rec := Record{Key: key}
+	err = s.db.QueryRow(ctx, `
+		SELECT request_hash, status_code, content_type, body
+		FROM idempotency_keys
+		WHERE key = $1;
+	`, key).Scan(&rec.RequestHash, &rec.StatusCode, &rec.ContentType, &rec.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	return &rec, false, nil
+}
+
+func (s *PostgresStore) Complete(ctx context.Context, key string, statusCode int, contentType string, body []byte) error {
+	query := `
+		UPDATE idempotency_keys
+		SET status_code = $2, content_type = $3, body = $4
+		WHERE key = $1;
+	`
+
+	if _, err := s.db.Exec(ctx, query, key, statusCode, contentType, body); err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Release(ctx context.Context, key string) error {
+	if _, err := s.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE key = $1;`, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) DeleteExpired(ctx context.Context) (int, error) {
+	tag, err := s.db.Exec(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= NOW();`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return int(tag.RowsAffected()), nil
+}
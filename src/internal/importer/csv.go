@@ -0,0 +1,175 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+// Fields that can be mapped to CSV columns
+var Fields = []string{"service_name", "price", "user_id", "start_date", "end_date"}
+
+// Options control how a CSV file is read
+type Options struct {
+	Delimiter rune
+	// DateLayout is a Go time layout used for start_date and end_date
+	DateLayout string
+	// Columns maps a field name to the header of the column holding it.
+	// Unmapped fields are looked up by their own name.
+	Columns map[string]string
+}
+
+// Row is one parsed data line. Err is set when the line could not be turned
+// into a subscription at all.
+type Row struct {
+	Line         int
+	Subscription models.CreateSubscription
+	Err          error
+}
+
+// Reader streams subscriptions out of a CSV file one row at a time
+type Reader struct {
+	csv        *csv.Reader
+	dateLayout string
+	index      map[string]int
+}
+
+func NewReader(r io.Reader, opts Options) (*Reader, error) {
+	cr := csv.NewReader(r)
+	cr.Comma = opts.Delimiter
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	positions := make(map[string]int, len(header))
+	for i, h := range header {
+		positions[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+
+	index := make(map[string]int, len(Fields))
+	for _, field := range Fields {
+		column := field
+		if mapped, ok := opts.Columns[field]; ok {
+			column = mapped
+		}
+
+		if i, ok := positions[strings.ToLower(column)]; ok {
+			index[field] = i
+		} else if field != "end_date" {
+			return nil, fmt.Errorf("column %q for %s not found in header", column, field)
+		}
+	}
+
+	return &Reader{csv: cr, dateLayout: opts.DateLayout, index: index}, nil
+}
+
+// Next returns the next row or io.EOF when the file is exhausted
+func (r *Reader) Next() (Row, error) {
+	record, err := r.csv.Read()
+
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Line: parseErr.Line, Err: parseErr.Err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	get := func(field string) string {
+		i, ok := r.index[field]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	line, _ := r.csv.FieldPos(0)
+	row := Row{Line: line}
+	var errs models.ValidationErrors
+
+	row.Subscription.ServiceName = get("service_name")
+	row.Subscription.UserID = get("user_id")
+
+	if price := get("price"); price != "" {
+		p, err := strconv.Atoi(price)
+		if err != nil {
+			errs = append(errs, "price must be an integer")
+		}
+		row.Subscription.Price = p
+	}
+
+	if row.Subscription.StartDate, err = r.date(get("start_date")); err != nil {
+		errs = append(errs, "start_date does not match date format")
+	}
+	if row.Subscription.EndDate, err = r.date(get("end_date")); err != nil {
+		errs = append(errs, "end_date does not match date format")
+	}
+
+	if len(errs) > 0 {
+		row.Err = errs
+	}
+	return row, nil
+}
+
+// date converts a value in the configured layout to models.DateLayout
+func (r *Reader) date(value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+
+	t, err := time.Parse(r.dateLayout, value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(models.DateLayout), nil
+}
+
+// ParseDateFormat turns a human date pattern such as "DD.MM.YYYY" into a Go
+// time layout.
+func ParseDateFormat(format string) (string, error) {
+	layout := strings.NewReplacer(
+		"YYYY", "2006",
+		"MM", "01",
+		"DD", "02",
+	).Replace(strings.ToUpper(format))
+
+	if strings.ContainsAny(layout, "YMD") || !strings.Contains(layout, "2006") {
+		return "", fmt.Errorf("unsupported date format %q, use YYYY, MM and DD", format)
+	}
+	return layout, nil
+}
+
+// ParseColumns parses a "field=Header,field=Header" mapping
+func ParseColumns(spec string) (map[string]string, error) {
+	columns := make(map[string]string)
+	if spec == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if !ok || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("invalid column mapping %q, expected field=Header", pair)
+		}
+
+		if !slices.Contains(Fields, field) {
+			return nil, fmt.Errorf("unknown field %q in column mapping", field)
+		}
+
+		columns[field] = strings.TrimSpace(column)
+	}
+	return columns, nil
+}
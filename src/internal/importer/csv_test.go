@@ -0,0 +1,159 @@
+package importer
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+const testUserID = "60601fee-2bf1-4721-ae6f-7636e79a0cba"
+
+func TestReaderNext(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		csv     string
+		want    models.CreateSubscription
+		wantErr bool
+		// invalid rows parse but fail models validation, as in the handler
+		invalid bool
+	}{
+		{
+			name: "full row",
+			csv:  "service_name,price,user_id,start_date,end_date\nNetflix,1999," + testUserID + ",2026-01-01,2026-12-31\n",
+			want: models.CreateSubscription{ServiceName: "Netflix", Price: 1999, UserID: testUserID, StartDate: "2026-01-01", EndDate: "2026-12-31"},
+		},
+		{
+			name: "empty end date is open-ended",
+			csv:  "service_name,price,user_id,start_date,end_date\nNetflix,1999," + testUserID + ",2026-01-01,\n",
+			want: models.CreateSubscription{ServiceName: "Netflix", Price: 1999, UserID: testUserID, StartDate: "2026-01-01"},
+		},
+		{
+			name: "end date column may be missing",
+			csv:  "service_name,price,user_id,start_date\nNetflix,1999," + testUserID + ",2026-01-01\n",
+			want: models.CreateSubscription{ServiceName: "Netflix", Price: 1999, UserID: testUserID, StartDate: "2026-01-01"},
+		},
+		{
+			name: "mapped columns, delimiter and date format",
+			opts: Options{Delimiter: ';', DateLayout: "02.01.2006", Columns: map[string]string{"service_name": "Service", "price": "Amount"}},
+			csv:  "Service;Amount;user_id;start_date;end_date\nSpotify;499;" + testUserID + ";15.03.2026;14.03.2027\n",
+			want: models.CreateSubscription{ServiceName: "Spotify", Price: 499, UserID: testUserID, StartDate: "2026-03-15", EndDate: "2027-03-14"},
+		},
+		{
+			name:    "price is not a number",
+			csv:     "service_name,price,user_id,start_date,end_date\nNetflix,abc," + testUserID + ",2026-01-01,\n",
+			wantErr: true,
+		},
+		{
+			name:    "date in another format",
+			csv:     "service_name,price,user_id,start_date,end_date\nNetflix,1999," + testUserID + ",01.01.2026,\n",
+			wantErr: true,
+		},
+		{
+			name:    "end before start",
+			csv:     "service_name,price,user_id,start_date,end_date\nNetflix,1999," + testUserID + ",2026-01-01,2025-12-31\n",
+			invalid: true,
+		},
+		{
+			name:    "negative price",
+			csv:     "service_name,price,user_id,start_date,end_date\nNetflix,-1," + testUserID + ",2026-01-01,\n",
+			invalid: true,
+		},
+		{
+			name:    "missing service name",
+			csv:     "service_name,price,user_id,start_date,end_date\n,1999," + testUserID + ",2026-01-01,\n",
+			invalid: true,
+		},
+		{
+			name:    "user ID is not a UUID",
+			csv:     "service_name,price,user_id,start_date,end_date\nNetflix,1999,42,2026-01-01,\n",
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if opts.Delimiter == 0 {
+				opts.Delimiter = ','
+			}
+			if opts.DateLayout == "" {
+				opts.DateLayout = models.DateLayout
+			}
+
+			r, err := NewReader(strings.NewReader(tt.csv), opts)
+			if err != nil {
+				t.Fatalf("NewReader: %v", err)
+			}
+			row, err := r.Next()
+			if err != nil {
+				t.Fatalf("Next: %v", err)
+			}
+			if row.Line != 2 {
+				t.Errorf("Line = %d, want 2", row.Line)
+			}
+
+			if tt.wantErr {
+				if row.Err == nil {
+					t.Fatal("Err = nil, want a parse error")
+				}
+				return
+			}
+			if row.Err != nil {
+				t.Fatalf("Err = %v", row.Err)
+			}
+
+			err = row.Subscription.Validate()
+			if tt.invalid {
+				if err == nil {
+					t.Fatal("Validate() = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Validate() = %v", err)
+			}
+			if row.Subscription != tt.want {
+				t.Errorf("Subscription = %+v, want %+v", row.Subscription, tt.want)
+			}
+
+			if _, err := r.Next(); err != io.EOF {
+				t.Errorf("second Next error = %v, want io.EOF", err)
+			}
+		})
+	}
+}
+
+func TestNewReaderMissingColumn(t *testing.T) {
+	_, err := NewReader(strings.NewReader("service_name,price,user_id\n"), Options{Delimiter: ','})
+	if err == nil || !strings.Contains(err.Error(), "start_date") {
+		t.Fatalf("NewReader error = %v, want the missing start_date column", err)
+	}
+}
+
+func TestParseDateFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: "YYYY-MM-DD", want: "2006-01-02"},
+		{format: "dd.mm.yyyy", want: "02.01.2006"},
+		{format: "MM/DD/YYYY", want: "01/02/2006"},
+		{format: "DD.MM.YY", wantErr: true},
+		{format: "YYYY-M-D", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseDateFormat(tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseDateFormat(%q) error = %v, wantErr %v", tt.format, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseDateFormat(%q) = %q, want %q", tt.format, got, tt.want)
+		}
+	}
+}
@@ -2,13 +2,34 @@ package initializers
 
 import (
 	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/configs"
 )
 
-func NewFiberConfig() fiber.Config {
+func NewFiberConfig(httpCfg configs.HTTPConfig) fiber.Config {
 	cfg := fiber.Config{
 		ServerHeader:  "EMob",
 		AppName:       "EMob App v0.1-beta",
 		CaseSensitive: true,
+		// Large uploads such as CSV imports are read as a stream. Bodies
+		// past BodyLimit are streamed rather than refused, middleware.BodyLimit
+		// enforces the limits per route.
+		StreamRequestBody: true,
+		ReadTimeout:       httpCfg.ReadTimeout,
+		WriteTimeout:      httpCfg.WriteTimeout,
+		IdleTimeout:       httpCfg.IdleTimeout,
+		BodyLimit:         httpCfg.BodyLimit,
+		ReadBufferSize:    httpCfg.ReadBufferSize,
+		Concurrency:       httpCfg.Concurrency,
+	}
+
+	// c.IP, c.Protocol and c.Hostname only believe the forwarded headers
+	// when the connection comes from a trusted proxy
+	if len(httpCfg.TrustedProxies) > 0 {
+		cfg.ProxyHeader = httpCfg.ProxyHeader
+		cfg.EnableTrustedProxyCheck = true
+		cfg.TrustedProxies = httpCfg.TrustedProxies
+		cfg.EnableIPValidation = true
 	}
 
 	return cfg
@@ -1,22 +1,50 @@
 package initializers
 
 import (
-	"os"
+	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/gofiber/fiber/v2/middleware/logger"
+
+	"github.com/nurkenspashev92/emob/internal/logging"
 )
 
+// NewLogger writes one access log line per request through the request
+// logger, so it must run after middleware.RequestID.
 func NewLogger() fiber.Handler {
-	return logger.New(logger.Config{
-		Next:          nil,
-		Done:          nil,
-		Format:        "${time} | ${pid} | ${ip}:${port} | ${status}| ${method} | ${path} |${latency} | ${error}\n",
-		TimeFormat:    "15:04:05",
-		TimeZone:      "Local",
-		TimeInterval:  500 * time.Millisecond,
-		Output:        os.Stdout,
-		DisableColors: false,
-	})
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		level := slog.LevelInfo
+		if status >= fiber.StatusInternalServerError {
+			level = slog.LevelError
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Method()),
+			slog.String("path", c.Path()),
+			slog.String("route", c.Route().Path),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("ip", c.IP()),
+		}
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+
+		ctx := c.UserContext()
+		logging.FromContext(ctx).LogAttrs(ctx, level, "request", attrs...)
+
+		return err
+	}
 }
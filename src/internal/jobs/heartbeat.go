@@ -0,0 +1,13 @@
+package jobs
+
+import (
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/health"
+)
+
+// newHeartbeat tolerates a few missed ticks plus slow iterations before the
+// liveness probe declares a job stuck.
+func newHeartbeat(interval time.Duration) *health.Heartbeat {
+	return health.NewHeartbeat(3*interval + time.Minute)
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/idempotency"
+)
+
+// IdempotencyCleanupJob deletes idempotency records past their TTL
+type IdempotencyCleanupJob struct {
+	store     idempotency.Store
+	interval  time.Duration
+	heartbeat *health.Heartbeat
+}
+
+func NewIdempotencyCleanupJob(store idempotency.Store, interval time.Duration) *IdempotencyCleanupJob {
+	return &IdempotencyCleanupJob{store: store, interval: interval, heartbeat: newHeartbeat(interval)}
+}
+
+func (j *IdempotencyCleanupJob) Heartbeat() *health.Heartbeat {
+	return j.heartbeat
+}
+
+func (j *IdempotencyCleanupJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		deleted, err := j.store.DeleteExpired(ctx)
+		j.heartbeat.Beat()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("Idempotency cleanup failed", "error", err)
+			}
+			continue
+		}
+
+		if deleted > 0 {
+			slog.Info("Idempotency cleanup removed expired keys", "count", deleted)
+		}
+	}
+}
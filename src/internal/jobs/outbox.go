@@ -0,0 +1,64 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/events"
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// OutboxRelayJob moves committed domain events from the outbox table to a
+// publisher.
+type OutboxRelayJob struct {
+	repo      *repositories.OutboxRepository
+	publisher events.Publisher
+	interval  time.Duration
+	batchSize int
+	heartbeat *health.Heartbeat
+}
+
+func NewOutboxRelayJob(db *pgxpool.Pool, publisher events.Publisher, cfg configs.OutboxConfig) *OutboxRelayJob {
+	return &OutboxRelayJob{
+		repo:      repositories.NewOutboxRepository(db),
+		publisher: publisher,
+		interval:  cfg.PollInterval,
+		batchSize: cfg.BatchSize,
+		heartbeat: newHeartbeat(cfg.PollInterval),
+	}
+}
+
+func (j *OutboxRelayJob) Heartbeat() *health.Heartbeat {
+	return j.heartbeat
+}
+
+// Run drains the outbox on every tick until ctx is cancelled. Full batches
+// are followed by the next one straight away.
+func (j *OutboxRelayJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n, err := j.repo.Drain(ctx, j.batchSize, j.publisher.Publish)
+			j.heartbeat.Beat()
+			if err != nil && ctx.Err() == nil {
+				slog.Error("Outbox relay failed", "error", err)
+			}
+			if err != nil || n < j.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
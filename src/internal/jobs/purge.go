@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// PurgeJob permanently removes subscriptions that have been soft-deleted for
+// longer than the configured retention period.
+type PurgeJob struct {
+	repo      *repositories.SubscriptionRepository
+	retention time.Duration
+	interval  time.Duration
+	heartbeat *health.Heartbeat
+}
+
+func NewPurgeJob(db *pgxpool.Pool, cfg configs.PurgeConfig) *PurgeJob {
+	return &PurgeJob{
+		repo:      repositories.NewSubscriptionRepository(db),
+		retention: cfg.Retention,
+		interval:  cfg.Interval,
+		heartbeat: newHeartbeat(cfg.Interval),
+	}
+}
+
+func (j *PurgeJob) Heartbeat() *health.Heartbeat {
+	return j.heartbeat
+}
+
+// Run purges once immediately and then on every tick until ctx is cancelled
+func (j *PurgeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		j.purge(ctx)
+		j.heartbeat.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *PurgeJob) purge(ctx context.Context) {
+	purged, err := j.repo.PurgeDeletedSubscriptions(ctx, time.Now().Add(-j.retention))
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Purge job failed", "error", err)
+		}
+		return
+	}
+
+	if purged > 0 {
+		slog.Info("Purge job removed deleted subscriptions", "count", purged, "retention", j.retention)
+	}
+}
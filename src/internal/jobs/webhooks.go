@@ -0,0 +1,246 @@
+package jobs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/health"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+	"github.com/nurkenspashev92/emob/internal/webhooks"
+)
+
+// WebhookDeliveryJob posts queued webhook events to their receivers. Failed
+// deliveries are retried with exponential backoff until MaxAttempts, after
+// which they are marked dead and only come back through manual redelivery.
+type WebhookDeliveryJob struct {
+	repo      *repositories.WebhookRepository
+	client    *http.Client
+	cfg       configs.WebhookConfig
+	heartbeat *health.Heartbeat
+}
+
+func NewWebhookDeliveryJob(db *pgxpool.Pool, cfg configs.WebhookConfig) *WebhookDeliveryJob {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.AllowPrivateNetworks {
+		// The address is checked as it is dialled, after DNS resolution, so a
+		// name that resolves to an internal host is refused however it
+		// changes between registration and delivery. A proxy would do the
+		// dialling itself, deliveries go direct.
+		transport.Proxy = nil
+		transport.DialContext = (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicAddressOnly,
+		}).DialContext
+	}
+
+	return &WebhookDeliveryJob{
+		repo: repositories.NewWebhookRepository(db),
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   cfg.Timeout,
+			// A redirect is reported as a failure instead of being followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		cfg: cfg,
+		// A batch is delivered concurrently and takes up to one timeout
+		heartbeat: newHeartbeat(cfg.PollInterval + cfg.Timeout),
+	}
+}
+
+func (j *WebhookDeliveryJob) Heartbeat() *health.Heartbeat {
+	return j.heartbeat
+}
+
+// Run polls the queue until ctx is cancelled. A full batch is followed by the
+// next one straight away so a backlog drains without waiting for the ticker.
+func (j *WebhookDeliveryJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			n := j.deliverBatch(ctx)
+			j.heartbeat.Beat()
+			if n < j.cfg.BatchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *WebhookDeliveryJob) deliverBatch(ctx context.Context) int {
+	claimed, err := j.repo.ClaimDueDeliveries(ctx, j.cfg.BatchSize, 2*j.cfg.Timeout)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.Error("Webhook worker failed to claim deliveries", "error", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range claimed {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.deliver(ctx, d)
+		}()
+	}
+	wg.Wait()
+
+	return len(claimed)
+}
+
+func (j *WebhookDeliveryJob) deliver(ctx context.Context, d repositories.PendingDelivery) {
+	start := time.Now()
+	statusCode, err := j.post(ctx, d)
+	duration := time.Since(start)
+
+	// Interrupted by shutdown: the lease expires and the delivery is retried
+	if ctx.Err() != nil {
+		return
+	}
+
+	var retryAt *time.Time
+	if err != nil && d.Attempts < j.cfg.MaxAttempts {
+		t := time.Now().Add(backoff(j.cfg.BackoffBase, j.cfg.BackoffMax, d.Attempts))
+		retryAt = &t
+	}
+
+	if err := j.repo.RecordAttempt(ctx, d.ID, statusCode, err, duration, retryAt); err != nil {
+		slog.Error("Webhook worker failed to record delivery", "delivery_id", d.ID, "error", err)
+		return
+	}
+
+	if err != nil && retryAt == nil {
+		slog.Warn("Webhook delivery is dead", "delivery_id", d.ID, "url", d.URL, "attempts", d.Attempts, "error", err)
+	}
+}
+
+// post sends one signed request and returns the response status, if any
+func (j *WebhookDeliveryJob) post(ctx context.Context, d repositories.PendingDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "emob-webhooks/1")
+	req.Header.Set(webhooks.EventHeader, d.Event)
+	req.Header.Set(webhooks.DeliveryHeader, d.ID)
+	req.Header.Set(webhooks.SignatureHeader, webhooks.Sign(d.Secret, time.Now(), d.Payload))
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the delay with every attempt, capped at max, and spreads
+// retries over the upper half of the interval.
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	d := base
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+
+	half := d / 2
+	return half + rand.N(half+1)
+}
+
+// RenewalNoticeJob queues renewal.upcoming events for renewals falling within
+// the configured lead time.
+type RenewalNoticeJob struct {
+	repo      *repositories.WebhookRepository
+	lead      time.Duration
+	interval  time.Duration
+	heartbeat *health.Heartbeat
+}
+
+func NewRenewalNoticeJob(db *pgxpool.Pool, cfg configs.WebhookConfig) *RenewalNoticeJob {
+	return &RenewalNoticeJob{
+		repo:      repositories.NewWebhookRepository(db),
+		lead:      cfg.RenewalLead,
+		interval:  cfg.RenewalInterval,
+		heartbeat: newHeartbeat(cfg.RenewalInterval),
+	}
+}
+
+func (j *RenewalNoticeJob) Heartbeat() *health.Heartbeat {
+	return j.heartbeat
+}
+
+// Run checks once immediately and then on every tick until ctx is cancelled
+func (j *RenewalNoticeJob) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UTC()
+		if _, err := j.repo.EnqueueUpcomingRenewals(ctx, now, now.Add(j.lead)); err != nil && ctx.Err() == nil {
+			slog.Error("Renewal notice job failed", "error", err)
+		}
+		j.heartbeat.Beat()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publicAddressOnly refuses connections to addresses webhook receivers must
+// not point at: the host itself, private networks and link-local addresses,
+// which include cloud metadata endpoints.
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("webhook receiver address %q: %w", address, err)
+	}
+
+	addr := addrPort.Addr().Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		sharedAddressSpace.Contains(addr) {
+		return fmt.Errorf("webhook receiver address %s is not public", addr)
+	}
+	return nil
+}
+
+// sharedAddressSpace is the carrier-grade NAT range, private in all but name
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
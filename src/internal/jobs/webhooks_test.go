@@ -0,0 +1,38 @@
+package jobs
+
+import "testing"
+
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{address: "93.184.215.14:443", public: true},
+		{address: "[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", public: true},
+		{address: "127.0.0.1:80"},
+		{address: "[::1]:80"},
+		{address: "10.0.0.5:80"},
+		{address: "172.16.0.1:80"},
+		{address: "192.168.1.1:80"},
+		{address: "[fd00::1]:80"},
+		{address: "0.0.0.0:80"},
+		{address: "[::]:80"},
+		{address: "169.254.169.254:80"},
+		{address: "[fe80::1]:80"},
+		{address: "224.0.0.1:80"},
+		{address: "100.64.0.1:80"},
+		{address: "100.127.255.255:80"},
+		{address: "100.128.0.1:80", public: true},
+		// IPv4-mapped IPv6 must not hide a private address
+		{address: "[::ffff:127.0.0.1]:80"},
+		{address: "[::ffff:10.0.0.1]:80"},
+		{address: "not-an-address"},
+	}
+
+	for _, tt := range tests {
+		err := publicAddressOnly("tcp", tt.address, nil)
+		if (err == nil) != tt.public {
+			t.Errorf("publicAddressOnly(%q) = %v, want public %v", tt.address, err, tt.public)
+		}
+	}
+}
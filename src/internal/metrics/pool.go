@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector reads pgxpool statistics at scrape time
+type PoolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquires          *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	newConns          *prometheus.Desc
+	lifetimeDestroys  *prometheus.Desc
+	idleDestroys      *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &PoolCollector{
+		pool:              pool,
+		acquiredConns:     desc("acquired_connections", "Connections currently checked out of the pool."),
+		idleConns:         desc("idle_connections", "Idle connections in the pool."),
+		constructingConns: desc("constructing_connections", "Connections being opened."),
+		totalConns:        desc("total_connections", "All connections in the pool."),
+		maxConns:          desc("max_connections", "Maximum size of the pool."),
+		acquires:          desc("acquires_total", "Successful connection acquires."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Time spent waiting for successful acquires."),
+		canceledAcquires:  desc("canceled_acquires_total", "Acquires cancelled by their context."),
+		emptyAcquires:     desc("empty_acquires_total", "Acquires that had to wait because the pool was empty."),
+		newConns:          desc("new_connections_total", "Connections opened."),
+		lifetimeDestroys:  desc("max_lifetime_destroys_total", "Connections closed for exceeding their maximum lifetime."),
+		idleDestroys:      desc("max_idle_destroys_total", "Connections closed for being idle too long."),
+	}
+}
+
+func (p *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(p, ch)
+}
+
+func (p *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	s := p.pool.Stat()
+
+	gauge := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v)
+	}
+	counter := func(d *prometheus.Desc, v float64) {
+		ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v)
+	}
+
+	gauge(p.acquiredConns, float64(s.AcquiredConns()))
+	gauge(p.idleConns, float64(s.IdleConns()))
+	gauge(p.constructingConns, float64(s.ConstructingConns()))
+	gauge(p.totalConns, float64(s.TotalConns()))
+	gauge(p.maxConns, float64(s.MaxConns()))
+	counter(p.acquires, float64(s.AcquireCount()))
+	counter(p.acquireDuration, s.AcquireDuration().Seconds())
+	counter(p.canceledAcquires, float64(s.CanceledAcquireCount()))
+	counter(p.emptyAcquires, float64(s.EmptyAcquireCount()))
+	counter(p.newConns, float64(s.NewConnsCount()))
+	counter(p.lifetimeDestroys, float64(s.MaxLifetimeDestroyCount()))
+	counter(p.idleDestroys, float64(s.MaxIdleDestroyCount()))
+}
@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+)
+
+// Authenticate resolves the API key sent as "Authorization: Bearer <key>" or
+// X-API-Key and puts its principal in the user context. Requests without a
+// key go through anonymously, handlers that need a caller check for one. A
+// key that is sent but unknown is refused.
+func Authenticate(keys auth.Keys) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := auth.RequestKey(c.Get(fiber.HeaderAuthorization), c.Get("X-API-Key"))
+		if key == "" {
+			return c.Next()
+		}
+
+		p, ok := keys.Authenticate(key)
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Invalid API key",
+			})
+		}
+
+		c.SetUserContext(auth.WithPrincipal(c.UserContext(), p))
+		return c.Next()
+	}
+}
+
+// RequireAdmin refuses requests that were not authenticated with an admin
+// API key
+func RequireAdmin() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		p, ok := auth.FromContext(c.UserContext())
+		if !ok {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"status":  "error",
+				"message": "An API key is required",
+			})
+		}
+		if !p.IsAdmin() {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Only admins may use this endpoint",
+			})
+		}
+		return c.Next()
+	}
+}
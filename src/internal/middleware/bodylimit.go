@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit refuses request bodies larger than limit bytes. The server
+// streams large bodies instead of refusing them, so a handler reading the
+// whole body would otherwise buffer any size. Paths in streaming read their
+// body as it arrives and get their own limit, 0 leaving them unbounded.
+func BodyLimit(limit int, streaming map[string]int) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		path := c.Path()
+		if len(path) > 1 {
+			path = strings.TrimSuffix(path, "/")
+		}
+
+		bodyLimit, stream := streaming[path]
+		if !stream {
+			bodyLimit = limit
+		}
+		if bodyLimit <= 0 {
+			return c.Next()
+		}
+
+		if c.Request().Header.ContentLength() > bodyLimit {
+			return bodyTooLarge(c, bodyLimit)
+		}
+
+		// Chunked bodies have no length up front. Streaming routes count the
+		// bytes as they read them, the others are read here up to the limit.
+		body := c.Context().RequestBodyStream()
+		if stream || body == nil || c.Request().Header.ContentLength() != -1 {
+			return c.Next()
+		}
+
+		data, err := io.ReadAll(io.LimitReader(body, int64(bodyLimit)+1))
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "failed to read request body")
+		}
+		if len(data) > bodyLimit {
+			return bodyTooLarge(c, bodyLimit)
+		}
+		c.Request().SetBody(data)
+
+		return c.Next()
+	}
+}
+
+func bodyTooLarge(c *fiber.Ctx, limit int) error {
+	// The rest of the body is never read, the connection can not be reused
+	c.Context().SetConnectionClose()
+
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{
+		"status":  "error",
+		"message": fmt.Sprintf("request body must not exceed %d bytes", limit),
+	})
+}
@@ -1,11 +1,101 @@
 package middleware
 
-import "github.com/gofiber/fiber/v2"
+import (
+	"strconv"
+	"strings"
 
-func CorsHandler(c *fiber.Ctx) error {
-	c.Set("Access-Control-Allow-Origin", "*")
-	c.Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS, PATCH")
-	c.Set("Access-Control-Allow-Headers", "Accept, Content-Type, Authorization")
+	"github.com/gofiber/fiber/v2"
 
-	return c.Next()
+	"github.com/nurkenspashev92/emob/configs"
+)
+
+// Cors applies the configured CORS policy and answers preflight requests
+// without hitting the rest of the stack.
+func Cors(cfg configs.CORSConfig) fiber.Handler {
+	allowMethods := strings.Join(cfg.AllowMethods, ", ")
+	allowHeaders := strings.Join(cfg.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(cfg.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(cfg.MaxAge)
+
+	allowAny := false
+	exact := make(map[string]struct{})
+	wildcards := make([]string, 0)
+	for _, o := range cfg.AllowOrigins {
+		switch {
+		case o == "*":
+			allowAny = true
+		case strings.Contains(o, "://*."):
+			// "https://*.example.com" matches any subdomain, but not the apex
+			scheme, host, _ := strings.Cut(o, "://*")
+			wildcards = append(wildcards, strings.ToLower(scheme+"://")+"|"+strings.ToLower(host))
+		default:
+			exact[strings.ToLower(o)] = struct{}{}
+		}
+	}
+
+	// listed reports origins allowed by name rather than by "*"
+	listed := func(origin string) bool {
+		origin = strings.ToLower(origin)
+		if _, ok := exact[origin]; ok {
+			return true
+		}
+
+		for _, w := range wildcards {
+			scheme, suffix, _ := strings.Cut(w, "|")
+			if strings.HasPrefix(origin, scheme) &&
+				strings.HasSuffix(origin, suffix) &&
+				len(origin) > len(scheme)+len(suffix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(c *fiber.Ctx) error {
+		origin := c.Get(fiber.HeaderOrigin)
+		preflight := c.Method() == fiber.MethodOptions && c.Get(fiber.HeaderAccessControlRequestMethod) != ""
+
+		c.Vary(fiber.HeaderOrigin)
+
+		named := origin != "" && listed(origin)
+		if origin == "" || !(named || allowAny) {
+			if preflight {
+				return c.SendStatus(fiber.StatusNoContent)
+			}
+			return c.Next()
+		}
+
+		// Only origins listed by name may send credentials, "*" would let
+		// any site read responses with the user's cookies
+		if named {
+			c.Set(fiber.HeaderAccessControlAllowOrigin, origin)
+			if cfg.AllowCredentials {
+				c.Set(fiber.HeaderAccessControlAllowCredentials, "true")
+			}
+		} else {
+			c.Set(fiber.HeaderAccessControlAllowOrigin, "*")
+		}
+
+		if !preflight {
+			if exposeHeaders != "" {
+				c.Set(fiber.HeaderAccessControlExposeHeaders, exposeHeaders)
+			}
+			return c.Next()
+		}
+
+		c.Vary(fiber.HeaderAccessControlRequestMethod, fiber.HeaderAccessControlRequestHeaders)
+		c.Set(fiber.HeaderAccessControlAllowMethods, allowMethods)
+
+		if allowHeaders != "" {
+			c.Set(fiber.HeaderAccessControlAllowHeaders, allowHeaders)
+		} else if h := c.Get(fiber.HeaderAccessControlRequestHeaders); h != "" {
+			c.Set(fiber.HeaderAccessControlAllowHeaders, h)
+		}
+
+		if cfg.MaxAge > 0 {
+			c.Set(fiber.HeaderAccessControlMaxAge, maxAge)
+		}
+
+		return c.SendStatus(fiber.StatusNoContent)
+	}
 }
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/idempotency"
+	"github.com/nurkenspashev92/emob/internal/logging"
+)
+
+const maxIdempotencyKeyLength = 255
+
+// Idempotency replays the stored response when a request is retried with the
+// same Idempotency-Key header. Reusing a key with a different body, or while
+// the first request is still running, is rejected with 409. A request that
+// has held its key for longer than lease is taken to have died, and a retry
+// runs in its place.
+func Idempotency(store idempotency.Store, ttl, lease time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := c.Get("Idempotency-Key")
+		if key == "" {
+			return c.Next()
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Idempotency-Key is too long",
+			})
+		}
+
+		// Keys are scoped per route and caller so clients cannot collide. The
+		// scope is hashed, the path and caller have no length limit here.
+		scopeSum := sha256.Sum256([]byte(c.Method() + " " + c.Path() + "\n" + idempotencyCaller(c) + "\n" + key))
+		scope := hex.EncodeToString(scopeSum[:])
+
+		h := sha256.New()
+		h.Write([]byte(c.Method() + " " + c.Path() + "\n"))
+		h.Write(c.Body())
+		hash := hex.EncodeToString(h.Sum(nil))
+
+		existing, started, err := store.Begin(c.UserContext(), scope, hash, ttl, lease)
+		if err != nil {
+			logging.FromContext(c.UserContext()).Error("Idempotency store error", "error", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"status":  "error",
+				"message": "Could not process Idempotency-Key",
+			})
+		}
+
+		if !started {
+			if existing.RequestHash != hash {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"status":  "error",
+					"message": "Idempotency-Key was already used with a different request",
+				})
+			}
+
+			if existing.InProgress() {
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"status":  "error",
+					"message": "A request with this Idempotency-Key is still being processed",
+				})
+			}
+
+			c.Set("Idempotent-Replayed", "true")
+			c.Set(fiber.HeaderContentType, existing.ContentType)
+			return c.Status(existing.StatusCode).Send(existing.Body)
+		}
+
+		if err := c.Next(); err != nil {
+			releaseIdempotencyKey(c, store, scope)
+			return err
+		}
+
+		// Server errors are not final, let the client retry them for real
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError {
+			releaseIdempotencyKey(c, store, scope)
+			return nil
+		}
+
+		contentType := string(c.Response().Header.ContentType())
+		if err := store.Complete(c.UserContext(), scope, status, contentType, c.Response().Body()); err != nil {
+			logging.FromContext(c.UserContext()).Error("Idempotency store error", "error", err)
+		}
+
+		return nil
+	}
+}
+
+func releaseIdempotencyKey(c *fiber.Ctx, store idempotency.Store, scope string) {
+	if err := store.Release(c.UserContext(), scope); err != nil {
+		logging.FromContext(c.UserContext()).Error("Idempotency store error", "error", err)
+	}
+}
+
+// idempotencyCaller names the owner of a key scope. Only an API key proves
+// who the caller is; anonymous requests fall back to the X-User-ID they claim.
+func idempotencyCaller(c *fiber.Ctx) string {
+	if p, ok := auth.FromContext(c.UserContext()); ok {
+		return "key:" + p.Subject
+	}
+	return "anonymous:" + c.Get("X-User-ID")
+}
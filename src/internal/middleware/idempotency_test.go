@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/idempotency"
+)
+
+func TestIdempotencyScope(t *testing.T) {
+	keys := auth.Keys{
+		auth.HashKey("alice-key"): {Subject: "alice", Role: auth.RoleUser},
+		auth.HashKey("bob-key"):   {Subject: "bob", Role: auth.RoleUser},
+	}
+
+	app := fiber.New()
+	app.Use(Authenticate(keys))
+	app.Post("/", Idempotency(idempotency.NewMemoryStore(), time.Hour, time.Minute), func(c *fiber.Ctx) error {
+		return c.Status(fiber.StatusCreated).SendString(string(c.Body()))
+	})
+
+	send := func(apiKey, userID, body string) (int, bool) {
+		req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "k1")
+		if apiKey != "" {
+			req.Header.Set("X-API-Key", apiKey)
+		}
+		if userID != "" {
+			req.Header.Set("X-User-ID", userID)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		return resp.StatusCode, resp.Header.Get("Idempotent-Replayed") == "true"
+	}
+
+	tests := []struct {
+		name         string
+		apiKey       string
+		userID       string
+		body         string
+		wantStatus   int
+		wantReplayed bool
+	}{
+		{name: "first request", apiKey: "alice-key", body: "one", wantStatus: 201},
+		{name: "retry is replayed", apiKey: "alice-key", body: "one", wantStatus: 201, wantReplayed: true},
+		{name: "claimed user ID does not change the scope", apiKey: "alice-key", userID: "bob", body: "one", wantStatus: 201, wantReplayed: true},
+		{name: "reuse with another body conflicts", apiKey: "alice-key", body: "two", wantStatus: 409},
+		{name: "another key has its own scope", apiKey: "bob-key", body: "two", wantStatus: 201},
+		{name: "anonymous caller claiming alice has its own scope", userID: "alice", body: "three", wantStatus: 201},
+		{name: "anonymous retry is replayed", userID: "alice", body: "three", wantStatus: 201, wantReplayed: true},
+	}
+
+	for _, tt := range tests {
+		status, replayed := send(tt.apiKey, tt.userID, tt.body)
+		if status != tt.wantStatus || replayed != tt.wantReplayed {
+			t.Errorf("%s: status %d replayed %v, want %d %v", tt.name, status, replayed, tt.wantStatus, tt.wantReplayed)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// RequireIfMatch rejects unsafe requests that do not state which version of
+// the resource they are based on. When disabled If-Match stays optional.
+func RequireIfMatch(required bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !required {
+			return c.Next()
+		}
+
+		switch c.Method() {
+		case fiber.MethodPut, fiber.MethodPatch, fiber.MethodDelete:
+			if c.Get(fiber.HeaderIfMatch) == "" {
+				return c.Status(fiber.StatusPreconditionRequired).JSON(fiber.Map{
+					"status":  "error",
+					"message": "If-Match header is required",
+				})
+			}
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+
+	"github.com/nurkenspashev92/emob/internal/metrics"
+)
+
+// Metrics records the count and latency of every request, labelled with the
+// route template rather than the raw path to keep the label set bounded.
+// Handlers answer 404 themselves, so a 404 error comes from the router finding
+// no route; those requests share the "unmatched" label.
+func Metrics() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		route := c.Route().Path
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				status = fe.Code
+			}
+			if status == fiber.StatusNotFound {
+				route = "unmatched"
+			}
+		}
+
+		// Label values are kept, so the method must not alias the request
+		labels := []string{utils.CopyString(c.Method()), route, strconv.Itoa(status)}
+		metrics.HTTPRequests.WithLabelValues(labels...).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(labels...).Observe(time.Since(start).Seconds())
+
+		return err
+	}
+}
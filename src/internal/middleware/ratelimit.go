@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/auth"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/ratelimit"
+)
+
+type rateLimitRoute struct {
+	method   string
+	segments []string
+	pattern  string
+	limit    ratelimit.Limit
+}
+
+// RateLimit applies a token bucket per client key and route. Routes without
+// a rule share the default quota.
+func RateLimit(cfg configs.RateLimitConfig, store ratelimit.Store) fiber.Handler {
+	if !cfg.Enabled {
+		return func(c *fiber.Ctx) error {
+			return c.Next()
+		}
+	}
+
+	routes := make([]rateLimitRoute, 0, len(cfg.Routes))
+	for _, r := range cfg.Routes {
+		routes = append(routes, rateLimitRoute{
+			method:   r.Method,
+			segments: splitPath(r.Path),
+			pattern:  r.Method + " " + r.Path,
+			limit:    ratelimit.Limit{Requests: r.Requests, Period: r.Period},
+		})
+	}
+	defaultLimit := ratelimit.Limit{Requests: cfg.Requests, Period: cfg.Period}
+
+	return func(c *fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions {
+			return c.Next()
+		}
+
+		scope, limit := "default", defaultLimit
+		for _, r := range routes {
+			if r.matches(c.Method(), c.Path()) {
+				scope, limit = r.pattern, r.limit
+				break
+			}
+		}
+
+		res, err := store.Take(c.Context(), scope+"|"+clientKey(c, cfg.KeyBy), limit)
+		if err != nil {
+			// A broken store should not take the API down with it
+			logging.FromContext(c.UserContext()).Error("Rate limit store error", "error", err)
+			return c.Next()
+		}
+
+		c.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+		c.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Requests, ceilSeconds(limit.Period)))
+
+		if !res.Allowed {
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(ceilSeconds(res.RetryAfter)))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"type":   "about:blank",
+				"title":  "Too Many Requests",
+				"status": fiber.StatusTooManyRequests,
+				"detail": fmt.Sprintf("Rate limit of %d requests per %s exceeded", limit.Requests, limit.Period),
+			}, "application/problem+json")
+		}
+
+		return c.Next()
+	}
+}
+
+// clientKey picks the first available identity in the configured order. API
+// keys and users only count once authenticated, a client could otherwise
+// get a fresh bucket per request by making up header values.
+func clientKey(c *fiber.Ctx, keyBy []string) string {
+	p, authenticated := auth.FromContext(c.UserContext())
+	for _, k := range keyBy {
+		switch k {
+		case "api_key":
+			if authenticated {
+				return "key:" + p.Subject
+			}
+		case "user":
+			if authenticated && p.Role == auth.RoleUser {
+				return "user:" + p.Subject
+			}
+		case "ip":
+			return "ip:" + c.IP()
+		}
+	}
+	return "ip:" + c.IP()
+}
+
+func (r rateLimitRoute) matches(method, path string) bool {
+	if r.method != method {
+		return false
+	}
+
+	segments := splitPath(path)
+	if len(segments) != len(r.segments) {
+		return false
+	}
+
+	for i, s := range r.segments {
+		if !strings.HasPrefix(s, ":") && s != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
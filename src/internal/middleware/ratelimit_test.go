@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/internal/auth"
+)
+
+func TestClientKey(t *testing.T) {
+	keys := auth.Keys{
+		auth.HashKey("user-key"):  {Subject: "alice", Role: auth.RoleUser},
+		auth.HashKey("admin-key"): {Subject: "ops", Role: auth.RoleAdmin},
+	}
+
+	tests := []struct {
+		name   string
+		keyBy  []string
+		apiKey string
+		userID string
+		want   string
+	}{
+		{name: "api key", keyBy: []string{"api_key", "ip"}, apiKey: "user-key", want: "key:alice"},
+		{name: "admin api key", keyBy: []string{"api_key", "ip"}, apiKey: "admin-key", want: "key:ops"},
+		{name: "anonymous falls back to ip", keyBy: []string{"api_key", "ip"}, want: "ip:0.0.0.0"},
+		{name: "claimed user is ignored", keyBy: []string{"user", "ip"}, userID: "alice", want: "ip:0.0.0.0"},
+		{name: "authenticated user", keyBy: []string{"user", "ip"}, apiKey: "user-key", want: "user:alice"},
+		{name: "admin is no user", keyBy: []string{"user", "ip"}, apiKey: "admin-key", want: "ip:0.0.0.0"},
+		{name: "ip first", keyBy: []string{"ip", "api_key"}, apiKey: "user-key", want: "ip:0.0.0.0"},
+		{name: "no usable identity", keyBy: []string{"user"}, want: "ip:0.0.0.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Use(Authenticate(keys))
+			var got string
+			app.Get("/", func(c *fiber.Ctx) error {
+				got = clientKey(c, tt.keyBy)
+				return nil
+			})
+
+			req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(fiber.HeaderAuthorization, "Bearer "+tt.apiKey)
+			}
+			if tt.userID != "" {
+				req.Header.Set("X-User-ID", tt.userID)
+			}
+			if _, err := app.Test(req); err != nil {
+				t.Fatalf("request: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("clientKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"log/slog"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nurkenspashev92/emob/internal/logging"
+)
+
+const maxRequestIDLength = 128
+
+// RequestID reuses the X-Request-ID sent by the caller or generates one,
+// echoes it in the response and stores it in c.Locals("requestid") for the
+// audit log. The user context gets a logger tagged with the request id and,
+// when the request is traced, the trace id.
+func RequestID(logger *slog.Logger) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Get(fiber.HeaderXRequestID)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		} else {
+			id = utils.CopyString(id)
+		}
+
+		c.Set(fiber.HeaderXRequestID, id)
+		c.Locals("requestid", id)
+
+		ctx := c.UserContext()
+		requestLogger := logger.With("request_id", id)
+
+		span := trace.SpanFromContext(ctx)
+		if sc := span.SpanContext(); sc.IsValid() {
+			span.SetAttributes(attribute.String("request.id", id))
+			requestLogger = requestLogger.With("trace_id", sc.TraceID().String())
+		}
+
+		c.SetUserContext(logging.WithLogger(ctx, requestLogger))
+		return c.Next()
+	}
+}
+
+// validRequestID keeps ids from callers short and printable, since they end
+// up in logs and response headers.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
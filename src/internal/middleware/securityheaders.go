@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/nurkenspashev92/emob/configs"
+)
+
+// SecurityHeaders sets the response headers that keep browsers from
+// sniffing content types, framing the API or downgrading to plain HTTP.
+// HSTS is only sent over HTTPS, as seen by the server or a trusted proxy.
+func SecurityHeaders(cfg configs.SecurityHeadersConfig) fiber.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(cfg.HSTSMaxAge.Seconds()), 10)
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if cfg.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+
+	return func(c *fiber.Ctx) error {
+		if hsts != "" && c.Protocol() == "https" {
+			c.Set(fiber.HeaderStrictTransportSecurity, hsts)
+		}
+		if cfg.NoSniff {
+			c.Set(fiber.HeaderXContentTypeOptions, "nosniff")
+		}
+		if cfg.FrameOptions != "" {
+			c.Set(fiber.HeaderXFrameOptions, cfg.FrameOptions)
+		}
+		if cfg.ReferrerPolicy != "" {
+			c.Set(fiber.HeaderReferrerPolicy, cfg.ReferrerPolicy)
+		}
+
+		return c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/nurkenspashev92/emob/internal/tracing"
+)
+
+// Tracing starts a server span per request, continuing the trace of an
+// incoming traceparent header. The span is stored in the user context, so
+// handlers must pass c.UserContext() on for database spans to nest under it.
+func Tracing() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Span attributes outlive the request, so nothing may point into
+		// the reused fasthttp buffers
+		method := utils.CopyString(c.Method())
+
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), requestHeaders{c})
+
+		ctx, span := otel.Tracer(tracing.Name).Start(ctx, method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(method),
+				semconv.URLPath(utils.CopyString(c.Path())),
+				semconv.ClientAddress(utils.CopyString(c.IP())),
+			),
+		)
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		var fe *fiber.Error
+		if errors.As(err, &fe) {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+
+		// The route template is only known once routing is done
+		if !(err != nil && status == fiber.StatusNotFound) {
+			span.SetName(method + " " + c.Route().Path)
+			span.SetAttributes(semconv.HTTPRoute(c.Route().Path))
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+		if status >= fiber.StatusInternalServerError {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.SetStatus(codes.Error, "")
+		}
+
+		return err
+	}
+}
+
+// requestHeaders adapts the fasthttp request headers to a TextMapCarrier
+type requestHeaders struct {
+	c *fiber.Ctx
+}
+
+func (h requestHeaders) Get(key string) string {
+	return h.c.Get(key)
+}
+
+func (h requestHeaders) Set(key, value string) {
+	h.c.Request().Header.Set(key, value)
+}
+
+func (h requestHeaders) Keys() []string {
+	keys := make([]string, 0)
+	for key := range h.c.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
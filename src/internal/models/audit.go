@@ -0,0 +1,31 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent represents a single recorded mutation
+type AuditEvent struct {
+	ID         int64           `json:"id" example:"1"`
+	EntityType string          `json:"entity_type" example:"subscription"`
+	EntityID   string          `json:"entity_id" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Action     string          `json:"action" example:"update"`
+	Actor      string          `json:"actor" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	Diff       json.RawMessage `json:"diff" swaggertype:"object"`
+	RequestID  string          `json:"request_id,omitempty" example:"3f1c1d4e-9c1b-4a53-8f5e-0c2c3c7f9a10"`
+	IP         string          `json:"ip,omitempty" example:"127.0.0.1"`
+	CreatedAt  time.Time       `json:"created_at" example:"2026-01-01T12:00:00Z"`
+}
+
+// AuditFilter for querying audit events
+type AuditFilter struct {
+	EntityID string
+	Actor    string
+	From     time.Time
+	To       time.Time
+	Limit    int
+	Offset   int
+}
@@ -0,0 +1,41 @@
+package models
+
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+
+	BatchModeAtomic  = "atomic"
+	BatchModePartial = "partial"
+)
+
+// BatchOperation is a single create, update or delete inside a batch request
+type BatchOperation struct {
+	Op      string              `json:"op" example:"update" enums:"create,update,delete"`
+	ID      string              `json:"id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	IfMatch *int                `json:"if_match,omitempty" example:"3"`
+	Body    *CreateSubscription `json:"body,omitempty"`
+}
+
+// BatchRequest for the bulk endpoint request body
+type BatchRequest struct {
+	Mode       string           `json:"mode" example:"atomic" enums:"atomic,partial"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchItemResult is the outcome of one operation in a batch
+type BatchItemResult struct {
+	Index        int           `json:"index" example:"0"`
+	Op           string        `json:"op" example:"update"`
+	Status       int           `json:"status" example:"200"`
+	Subscription *Subscription `json:"subscription,omitempty"`
+	Error        string        `json:"error,omitempty"`
+}
+
+// BatchResponse lists per-item results in request order
+type BatchResponse struct {
+	Mode      string            `json:"mode" example:"atomic"`
+	Succeeded int               `json:"succeeded" example:"2"`
+	Failed    int               `json:"failed" example:"0"`
+	Results   []BatchItemResult `json:"results"`
+}
@@ -0,0 +1,26 @@
+package models
+
+const (
+	ImportRowValid   = "valid"
+	ImportRowCreated = "created"
+	ImportRowInvalid = "invalid"
+	ImportRowFailed  = "failed"
+)
+
+// ImportRowResult describes what happened to one CSV line
+type ImportRowResult struct {
+	Line   int      `json:"line" example:"2"`
+	Status string   `json:"status" example:"created" enums:"valid,created,invalid,failed"`
+	ID     string   `json:"id,omitempty" example:"550e8400-e29b-41d4-a716-446655440000"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ImportReport summarises a CSV import
+type ImportReport struct {
+	DryRun  bool              `json:"dry_run" example:"false"`
+	Total   int               `json:"total" example:"3"`
+	Valid   int               `json:"valid" example:"2"`
+	Created int               `json:"created" example:"2"`
+	Failed  int               `json:"failed" example:"1"`
+	Rows    []ImportRowResult `json:"rows"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// SubscriptionChange is one event of the subscription change stream. ID is
+// the audit event id and doubles as the SSE event id.
+type SubscriptionChange struct {
+	ID           int64        `json:"id" example:"42"`
+	Type         string       `json:"type" example:"subscription.updated"`
+	Actor        string       `json:"actor" example:"60601fee-2bf1-4721-ae6f-7636e79a0cba"`
+	CreatedAt    time.Time    `json:"created_at" example:"2026-01-01T12:00:00Z"`
+	Subscription Subscription `json:"subscription"`
+}
+
+// SubscriptionChangeFilter narrows the change stream. Empty fields match all.
+type SubscriptionChangeFilter struct {
+	UserID      string
+	ServiceName string
+}
+
+func (f SubscriptionChangeFilter) Match(c SubscriptionChange) bool {
+	if f.UserID != "" && c.Subscription.UserID != f.UserID {
+		return false
+	}
+	if f.ServiceName != "" && !strings.EqualFold(c.Subscription.ServiceName, f.ServiceName) {
+		return false
+	}
+	return true
+}
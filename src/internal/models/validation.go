@@ -0,0 +1,86 @@
+package models
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const DateLayout = "2006-01-02"
+
+// ValidationErrors lists every problem found in a request body
+type ValidationErrors []string
+
+func (v ValidationErrors) Error() string {
+	return strings.Join(v, "; ")
+}
+
+// Validate checks the rules a subscription must satisfy before it is stored
+func (s CreateSubscription) Validate() error {
+	var errs ValidationErrors
+
+	if strings.TrimSpace(s.ServiceName) == "" {
+		errs = append(errs, "service_name is required")
+	} else if len(s.ServiceName) > 255 {
+		errs = append(errs, "service_name must be at most 255 characters")
+	}
+
+	if s.Price < 0 {
+		errs = append(errs, "price must not be negative")
+	}
+
+	if _, err := uuid.Parse(s.UserID); err != nil {
+		errs = append(errs, "user_id must be a valid UUID")
+	}
+
+	start, err := time.Parse(DateLayout, s.StartDate)
+	if err != nil {
+		errs = append(errs, "start_date must be in YYYY-MM-DD format")
+	}
+
+	if s.EndDate != "" {
+		end, err := time.Parse(DateLayout, s.EndDate)
+		switch {
+		case err != nil:
+			errs = append(errs, "end_date must be in YYYY-MM-DD format")
+		case !start.IsZero() && end.Before(start):
+			errs = append(errs, "end_date must not be before start_date")
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Validate checks the webhook URL and event names
+func (w CreateWebhook) Validate() error {
+	var errs ValidationErrors
+
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, "url must be an absolute http or https URL")
+	}
+
+	if len(w.Events) == 0 {
+		errs = append(errs, "events must not be empty")
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(WebhookEvents, e) {
+			errs = append(errs, "unknown event "+e)
+		}
+	}
+
+	if w.Secret != "" && len(w.Secret) < 16 {
+		errs = append(errs, "secret must be at least 16 characters")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	EventSubscriptionCreated  = "subscription.created"
+	EventSubscriptionUpdated  = "subscription.updated"
+	EventSubscriptionDeleted  = "subscription.deleted"
+	EventSubscriptionRestored = "subscription.restored"
+	EventSubscriptionPurged   = "subscription.purged"
+	EventRenewalUpcoming      = "renewal.upcoming"
+
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead"
+)
+
+// WebhookEvents lists every event a webhook can subscribe to
+var WebhookEvents = []string{
+	EventSubscriptionCreated,
+	EventSubscriptionUpdated,
+	EventSubscriptionDeleted,
+	EventSubscriptionRestored,
+	EventRenewalUpcoming,
+}
+
+// Webhook represents a registered receiver
+type Webhook struct {
+	ID        string    `json:"id" example:"8c0a3f4e-1b2c-4d5e-8f90-123456789abc"`
+	URL       string    `json:"url" example:"https://example.com/hooks/emob"`
+	Secret    string    `json:"secret,omitempty" example:"whsec_3q2-7wE..."`
+	Events    []string  `json:"events" example:"subscription.created,subscription.deleted"`
+	Active    bool      `json:"active" example:"true"`
+	CreatedAt time.Time `json:"created_at" example:"2026-01-01T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2026-01-01T12:00:00Z"`
+}
+
+// CreateWebhook for request body. An empty secret is generated.
+type CreateWebhook struct {
+	URL    string   `json:"url" example:"https://example.com/hooks/emob"`
+	Secret string   `json:"secret,omitempty" example:""`
+	Events []string `json:"events" example:"subscription.created,subscription.deleted"`
+	Active *bool    `json:"active,omitempty" example:"true"`
+}
+
+// WebhookEvent is the JSON body posted to receivers
+type WebhookEvent struct {
+	ID        string    `json:"id" example:"0b5f2f9e-7c55-4a8e-9d0e-3f3c0a1b2c3d"`
+	Type      string    `json:"type" example:"subscription.created"`
+	CreatedAt time.Time `json:"created_at" example:"2026-01-01T12:00:00Z"`
+	Data      any       `json:"data"`
+}
+
+// RenewalNotice is the data of a renewal.upcoming event
+type RenewalNotice struct {
+	RenewalDate  string       `json:"renewal_date" example:"2026-02-01"`
+	Subscription Subscription `json:"subscription"`
+}
+
+// WebhookDelivery is one event queued for one webhook
+type WebhookDelivery struct {
+	ID             string                   `json:"id" example:"5a1d3c2b-0e9f-4a8b-b7c6-d5e4f3a2b1c0"`
+	WebhookID      string                   `json:"webhook_id" example:"8c0a3f4e-1b2c-4d5e-8f90-123456789abc"`
+	Event          string                   `json:"event" example:"subscription.created"`
+	Payload        json.RawMessage          `json:"payload" swaggertype:"object"`
+	Status         string                   `json:"status" example:"pending" enums:"pending,succeeded,dead"`
+	Attempts       int                      `json:"attempts" example:"1"`
+	NextAttemptAt  time.Time                `json:"next_attempt_at" example:"2026-01-01T12:00:30Z"`
+	LastStatusCode *int                     `json:"last_status_code,omitempty" example:"500"`
+	LastError      *string                  `json:"last_error,omitempty" example:"unexpected status 500"`
+	DeliveredAt    *time.Time               `json:"delivered_at,omitempty" example:"2026-01-01T12:00:01Z"`
+	CreatedAt      time.Time                `json:"created_at" example:"2026-01-01T12:00:00Z"`
+	AttemptLog     []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+// WebhookDeliveryAttempt is a single HTTP call made for a delivery
+type WebhookDeliveryAttempt struct {
+	StatusCode *int      `json:"status_code,omitempty" example:"500"`
+	Error      *string   `json:"error,omitempty" example:"unexpected status 500"`
+	DurationMs int       `json:"duration_ms" example:"120"`
+	CreatedAt  time.Time `json:"created_at" example:"2026-01-01T12:00:00Z"`
+}
@@ -0,0 +1,85 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens   float64
+	updated  time.Time
+	capacity float64
+	rate     float64
+}
+
+// MemoryStore is an in-process token bucket store
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   make(map[string]*bucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit) (Result, error) {
+	now := s.now()
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok || b.capacity != capacity || b.rate != rate {
+		b = &bucket{tokens: capacity, updated: now, capacity: capacity, rate: rate}
+		s.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(b.capacity, b.tokens+elapsed*b.rate)
+	b.updated = now
+
+	res := Result{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / b.rate)
+	}
+
+	res.Remaining = int(b.tokens)
+	res.Reset = secondsToDuration((b.capacity - b.tokens) / b.rate)
+
+	return res, nil
+}
+
+// sweep drops buckets that have been idle long enough to be full again
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.rate >= b.capacity {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(sec float64) time.Duration {
+	return time.Duration(math.Ceil(sec * float64(time.Second)))
+}
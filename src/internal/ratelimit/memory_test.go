@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryStoreTake(t *testing.T) {
+	limit := Limit{Requests: 2, Period: 2 * time.Second}
+
+	tests := []struct {
+		name string
+		// advance moves the clock before the take
+		advance       time.Duration
+		key           string
+		wantAllowed   bool
+		wantRemaining int
+		wantRetry     time.Duration
+	}{
+		{name: "full bucket", key: "a", wantAllowed: true, wantRemaining: 1},
+		{name: "last token", key: "a", wantAllowed: true, wantRemaining: 0},
+		{name: "empty bucket", key: "a", wantRetry: time.Second},
+		{name: "half refilled", advance: 500 * time.Millisecond, key: "a", wantRetry: 500 * time.Millisecond},
+		{name: "one token refilled", advance: 500 * time.Millisecond, key: "a", wantAllowed: true, wantRemaining: 0},
+		{name: "other key has its own bucket", key: "b", wantAllowed: true, wantRemaining: 1},
+		{name: "refill stops at capacity", advance: time.Hour, key: "a", wantAllowed: true, wantRemaining: 1},
+	}
+
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		res, err := s.Take(context.Background(), tt.key, limit)
+		if err != nil {
+			t.Fatalf("%s: Take: %v", tt.name, err)
+		}
+		if res.Allowed != tt.wantAllowed || res.Remaining != tt.wantRemaining || res.RetryAfter != tt.wantRetry {
+			t.Errorf("%s: got allowed %v remaining %d retry %v, want %v %d %v",
+				tt.name, res.Allowed, res.Remaining, res.RetryAfter, tt.wantAllowed, tt.wantRemaining, tt.wantRetry)
+		}
+		if res.Limit != limit.Requests {
+			t.Errorf("%s: Limit = %d, want %d", tt.name, res.Limit, limit.Requests)
+		}
+	}
+}
+
+func TestMemoryStoreLimitChangeResetsBucket(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+
+	s.Take(context.Background(), "a", Limit{Requests: 1, Period: time.Minute})
+	res, _ := s.Take(context.Background(), "a", Limit{Requests: 5, Period: time.Minute})
+	if !res.Allowed || res.Remaining != 4 {
+		t.Fatalf("after a limit change got allowed %v remaining %d, want a fresh bucket", res.Allowed, res.Remaining)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	s.lastSweep = now
+
+	limit := Limit{Requests: 10, Period: time.Hour}
+	s.Take(context.Background(), "idle", limit)
+	now = now.Add(sweepInterval)
+	s.Take(context.Background(), "busy", limit)
+
+	// "idle" has only refilled a sixth of a token, it is kept
+	if _, ok := s.buckets["idle"]; !ok {
+		t.Fatal("a bucket that is not full again was swept")
+	}
+
+	now = now.Add(time.Hour)
+	s.Take(context.Background(), "other", limit)
+	if _, ok := s.buckets["idle"]; ok {
+		t.Error("a full idle bucket was not swept")
+	}
+}
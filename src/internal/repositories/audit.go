@@ -0,0 +1,284 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+type AuditRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAuditRepository(db *pgxpool.Pool) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const insertAuditQuery = `
+	INSERT INTO audit_events (
+		entity_type,
+		entity_id,
+		action,
+		actor,
+		before,
+		after,
+		diff,
+		request_id,
+		ip
+	) VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''));
+`
+
+// recordAudit appends an audit event inside the caller's transaction so the
+// event commits or rolls back together with the mutation it describes.
+func recordAudit(
+	ctx context.Context,
+	tx pgx.Tx,
+	entityType string,
+	entityID string,
+	action string,
+	before any,
+	after any,
+) error {
+
+	args, err := auditArgs(ctx, entityType, entityID, action, before, after)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, insertAuditQuery, args...); err != nil {
+		return fmt.Errorf("failed to write audit event: %w", err)
+	}
+
+	return nil
+}
+
+// auditArgs builds the insertAuditQuery arguments for one event
+func auditArgs(
+	ctx context.Context,
+	entityType string,
+	entityID string,
+	action string,
+	before any,
+	after any,
+) ([]any, error) {
+
+	diff, err := audit.Diff(before, after)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff audit snapshots: %w", err)
+	}
+
+	beforeJSON, err := marshalSnapshot(before)
+	if err != nil {
+		return nil, err
+	}
+	afterJSON, err := marshalSnapshot(after)
+	if err != nil {
+		return nil, err
+	}
+	diffJSON, err := json.Marshal(diff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit diff: %w", err)
+	}
+
+	meta := audit.MetaFrom(ctx)
+
+	return []any{
+		entityType,
+		entityID,
+		action,
+		meta.Actor,
+		beforeJSON,
+		afterJSON,
+		diffJSON,
+		meta.RequestID,
+		meta.IP,
+	}, nil
+}
+
+func marshalSnapshot(v any) ([]byte, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit snapshot: %w", err)
+	}
+
+	if string(raw) == "null" {
+		return nil, nil
+	}
+	return raw, nil
+}
+
+func (repo *AuditRepository) GetAuditEvents(
+	ctx context.Context,
+	filter models.AuditFilter,
+) ([]models.AuditEvent, error) {
+
+	query := `
+		SELECT
+			id,
+			entity_type,
+			entity_id,
+			action,
+			actor,
+			before,
+			after,
+			diff,
+			COALESCE(request_id, ''),
+			COALESCE(ip, ''),
+			created_at
+		FROM audit_events
+		WHERE 1 = 1
+	`
+
+	args := []interface{}{}
+	argID := 1
+
+	if filter.EntityID != "" {
+		query += fmt.Sprintf(" AND entity_id = $%d", argID)
+		args = append(args, filter.EntityID)
+		argID++
+	}
+
+	if filter.Actor != "" {
+		query += fmt.Sprintf(" AND actor = $%d", argID)
+		args = append(args, filter.Actor)
+		argID++
+	}
+
+	if !filter.From.IsZero() {
+		query += fmt.Sprintf(" AND created_at >= $%d", argID)
+		args = append(args, filter.From)
+		argID++
+	}
+
+	if !filter.To.IsZero() {
+		query += fmt.Sprintf(" AND created_at <= $%d", argID)
+		args = append(args, filter.To)
+		argID++
+	}
+
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d", argID, argID+1)
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := repo.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0)
+
+	for rows.Next() {
+		var e models.AuditEvent
+
+		err := rows.Scan(
+			&e.ID,
+			&e.EntityType,
+			&e.EntityID,
+			&e.Action,
+			&e.Actor,
+			&e.Before,
+			&e.After,
+			&e.Diff,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+
+		events = append(events, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return events, nil
+}
+
+// subscriptionChangesQuery reads audit events of the actions that are
+// streamed to clients, see migration 000009.
+const subscriptionChangesQuery = `
+	SELECT id, action, actor, COALESCE(after, before), created_at
+	FROM audit_events
+	WHERE entity_type = 'subscription'
+		AND action IN ('create', 'update', 'delete', 'restore')
+`
+
+// GetSubscriptionChange returns the change recorded by one audit event
+func (repo *AuditRepository) GetSubscriptionChange(
+	ctx context.Context,
+	id int64,
+) (*models.SubscriptionChange, error) {
+
+	changes, err := repo.querySubscriptionChanges(ctx, subscriptionChangesQuery+` AND id = $1;`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("subscription change %d not found", id)
+	}
+
+	return &changes[0], nil
+}
+
+// GetSubscriptionChangesAfter returns up to limit changes with an id greater
+// than afterID, oldest first. It is used to resume a stream.
+func (repo *AuditRepository) GetSubscriptionChangesAfter(
+	ctx context.Context,
+	afterID int64,
+	limit int,
+) ([]models.SubscriptionChange, error) {
+
+	return repo.querySubscriptionChanges(ctx, subscriptionChangesQuery+` AND id > $1 ORDER BY id LIMIT $2;`, afterID, limit)
+}
+
+func (repo *AuditRepository) querySubscriptionChanges(
+	ctx context.Context,
+	query string,
+	args ...any,
+) ([]models.SubscriptionChange, error) {
+
+	rows, err := repo.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscription changes: %w", err)
+	}
+	defer rows.Close()
+
+	changes := make([]models.SubscriptionChange, 0)
+	for rows.Next() {
+		var (
+			c        models.SubscriptionChange
+			action   string
+			snapshot []byte
+		)
+
+		if err := rows.Scan(&c.ID, &action, &c.Actor, &snapshot, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan subscription change: %w", err)
+		}
+
+		c.Type = subscriptionEvents[action]
+		if err := json.Unmarshal(snapshot, &c.Subscription); err != nil {
+			return nil, fmt.Errorf("failed to decode subscription snapshot: %w", err)
+		}
+
+		changes = append(changes, c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return changes, nil
+}
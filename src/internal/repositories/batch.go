@@ -0,0 +1,222 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/metrics"
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+// ErrBatchRolledBack marks operations of an atomic batch that were valid but
+// rolled back because another operation failed.
+var ErrBatchRolledBack = errors.New("rolled back because another operation failed")
+
+// BatchOutcome is the result of one batch operation. Subscription is nil for
+// deletions and failures.
+type BatchOutcome struct {
+	Subscription *models.Subscription
+	Err          error
+}
+
+// ApplyBatchPartial runs every operation in its own transaction, so a failing
+// operation does not affect the others.
+func (repo *SubscriptionRepository) ApplyBatchPartial(
+	ctx context.Context,
+	ops []models.BatchOperation,
+) []BatchOutcome {
+
+	outcomes := make([]BatchOutcome, len(ops))
+
+	for i, op := range ops {
+		var out BatchOutcome
+
+		switch op.Op {
+		case models.BatchOpCreate:
+			out.Subscription, out.Err = repo.CreateSubscriptions(ctx, *op.Body)
+		case models.BatchOpUpdate:
+			out.Subscription, out.Err = repo.UpdateSubscription(ctx, op.ID, *op.Body, ifMatchList(op.IfMatch))
+		case models.BatchOpDelete:
+			out.Err = repo.DeleteSubscription(ctx, op.ID, ifMatchList(op.IfMatch))
+		}
+
+		outcomes[i] = out
+	}
+
+	return outcomes
+}
+
+// ApplyBatchAtomic runs all operations in a single transaction. Statements
+// are pipelined with pgx.Batch in three round trips: lock the rows being
+// changed, apply the changes, then record the audit, outbox and webhook
+// events. If any operation fails nothing is committed, the returned error is
+// non-nil and the failing operation carries its own error while the rest
+// carry ErrBatchRolledBack.
+func (repo *SubscriptionRepository) ApplyBatchAtomic(
+	ctx context.Context,
+	ops []models.BatchOperation,
+) ([]BatchOutcome, error) {
+
+	outcomes := make([]BatchOutcome, len(ops))
+
+	type dates struct{ start, end time.Time }
+	parsed := make([]dates, len(ops))
+	for i, op := range ops {
+		if op.Body == nil {
+			continue
+		}
+
+		start, end, err := parseSubscriptionDates(*op.Body)
+		if err != nil {
+			return failBatch(outcomes, i, err)
+		}
+		parsed[i] = dates{start: start, end: end}
+	}
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// Round trip 1: lock existing rows and check their versions
+	before := make([]*models.Subscription, len(ops))
+	locks := &pgx.Batch{}
+	lockIndexes := make([]int, 0)
+	for i, op := range ops {
+		if op.Op == models.BatchOpUpdate || op.Op == models.BatchOpDelete {
+			locks.Queue(lockSubscriptionQuery, op.ID)
+			lockIndexes = append(lockIndexes, i)
+		}
+	}
+
+	if locks.Len() > 0 {
+		results := tx.SendBatch(ctx, locks)
+		for _, i := range lockIndexes {
+			s, err := scanSubscription(results.QueryRow())
+			if err == nil && ops[i].IfMatch != nil && *ops[i].IfMatch != s.Version {
+				err = ErrVersionMismatch
+			}
+			if err != nil {
+				results.Close()
+				return failBatch(outcomes, i, err)
+			}
+			before[i] = s
+		}
+		if err := results.Close(); err != nil {
+			return nil, fmt.Errorf("failed to lock subscriptions: %w", err)
+		}
+	}
+
+	// Round trip 2: apply the changes in request order
+	writes := &pgx.Batch{}
+	for i, op := range ops {
+		switch op.Op {
+		case models.BatchOpCreate:
+			writes.Queue(insertSubscriptionQuery,
+				op.Body.ServiceName,
+				op.Body.Price,
+				op.Body.UserID,
+				parsed[i].start,
+				nullDate(parsed[i].end),
+			)
+		case models.BatchOpUpdate:
+			writes.Queue(updateSubscriptionQuery,
+				op.Body.ServiceName,
+				op.Body.Price,
+				op.Body.UserID,
+				parsed[i].start,
+				nullDate(parsed[i].end),
+				op.ID,
+			)
+		case models.BatchOpDelete:
+			writes.Queue(softDeleteSubscriptionQuery, op.ID)
+		}
+	}
+
+	after := make([]*models.Subscription, len(ops))
+	results := tx.SendBatch(ctx, writes)
+	for i, op := range ops {
+		s, err := scanSubscription(results.QueryRow())
+		if err != nil {
+			results.Close()
+			return failBatch(outcomes, i, err)
+		}
+
+		after[i] = s
+		if op.Op != models.BatchOpDelete {
+			outcomes[i].Subscription = s
+		}
+	}
+	if err := results.Close(); err != nil {
+		return nil, fmt.Errorf("failed to apply batch: %w", err)
+	}
+
+	// Round trip 3: record what changed, as recordSubscriptionChange does
+	changes := &pgx.Batch{}
+	actions := make([]string, len(ops))
+	for i, op := range ops {
+		action := audit.ActionCreate
+		switch op.Op {
+		case models.BatchOpUpdate:
+			action = audit.ActionUpdate
+		case models.BatchOpDelete:
+			action = audit.ActionDelete
+		}
+		actions[i] = action
+		event := subscriptionEvents[action]
+
+		args, err := auditArgs(ctx, subscriptionEntity, after[i].ID, action, before[i], after[i])
+		if err != nil {
+			return nil, err
+		}
+		changes.Queue(insertAuditQuery, args...)
+
+		args, err = outboxArgs(subscriptionEntity, after[i].ID, event, after[i])
+		if err != nil {
+			return nil, err
+		}
+		changes.Queue(insertOutboxQuery, args...)
+
+		args, err = webhookEventArgs(event, after[i], "")
+		if err != nil {
+			return nil, err
+		}
+		changes.Queue(insertWebhookDeliveryQuery, args...)
+	}
+
+	if err := tx.SendBatch(ctx, changes).Close(); err != nil {
+		return nil, fmt.Errorf("failed to record batch changes: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, action := range actions {
+		metrics.SubscriptionChanged(action, 1)
+	}
+
+	return outcomes, nil
+}
+
+func failBatch(outcomes []BatchOutcome, failed int, err error) ([]BatchOutcome, error) {
+	for i := range outcomes {
+		outcomes[i] = BatchOutcome{Err: ErrBatchRolledBack}
+	}
+	outcomes[failed].Err = err
+
+	return outcomes, fmt.Errorf("batch operation %d failed: %w", failed, err)
+}
+
+func ifMatchList(version *int) []int {
+	if version == nil {
+		return nil
+	}
+	return []int{*version}
+}
@@ -0,0 +1,61 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+var ErrFeedTokenNotFound = errors.New("calendar feed token not found")
+
+type CalendarTokenRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCalendarTokenRepository(db *pgxpool.Pool) *CalendarTokenRepository {
+	return &CalendarTokenRepository{db: db}
+}
+
+// SaveTokenHash stores a new feed token hash for the user, replacing and thus
+// revoking any previous token.
+func (repo *CalendarTokenRepository) SaveTokenHash(
+	ctx context.Context,
+	userID string,
+	tokenHash string,
+) error {
+
+	query := `
+		INSERT INTO calendar_feed_tokens (user_id, token_hash, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (user_id) DO UPDATE
+		SET token_hash = EXCLUDED.token_hash, created_at = EXCLUDED.created_at;
+	`
+
+	if _, err := repo.db.Exec(ctx, query, userID, tokenHash); err != nil {
+		return fmt.Errorf("failed to save calendar feed token: %w", err)
+	}
+
+	return nil
+}
+
+func (repo *CalendarTokenRepository) GetTokenHash(
+	ctx context.Context,
+	userID string,
+) (string, error) {
+
+	query := `SELECT token_hash FROM calendar_feed_tokens WHERE user_id = $1;`
+
+	var hash string
+	err := repo.db.QueryRow(ctx, query, userID).Scan(&hash)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrFeedTokenNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get calendar feed token: %w", err)
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,184 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+// The lookups below answer many keys with one query. They back the GraphQL
+// dataloaders, which collect the keys requested while resolving one level of
+// a query. Service names are matched case-insensitively and returned in lower
+// case.
+
+// GetSubscriptionsByUsers returns up to limit of the live subscriptions of
+// every user, ordered by start date.
+func (repo *SubscriptionRepository) GetSubscriptionsByUsers(
+	ctx context.Context,
+	userIDs []string,
+	limit int,
+) (map[string][]models.Subscription, error) {
+
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY start_date, id) AS rn
+			FROM subscriptions
+			WHERE user_id = ANY($1) AND deleted_at IS NULL
+		) ranked
+		WHERE rn <= $2
+		ORDER BY start_date, id;
+	`
+
+	rows, err := repo.db.Query(ctx, query, userIDs, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions by users: %w", err)
+	}
+
+	return groupSubscriptions(rows, func(s *models.Subscription) string { return s.UserID })
+}
+
+// GetSubscriptionsByServices returns up to limit of the newest live
+// subscriptions of every service.
+func (repo *SubscriptionRepository) GetSubscriptionsByServices(
+	ctx context.Context,
+	serviceNames []string,
+	limit int,
+) (map[string][]models.Subscription, error) {
+
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY lower(service_name) ORDER BY created_at DESC, id) AS rn
+			FROM subscriptions
+			WHERE lower(service_name) = ANY($1) AND deleted_at IS NULL
+		) ranked
+		WHERE rn <= $2
+		ORDER BY created_at DESC, id;
+	`
+
+	rows, err := repo.db.Query(ctx, query, lowerAll(serviceNames), limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query subscriptions by services: %w", err)
+	}
+
+	return groupSubscriptions(rows, func(s *models.Subscription) string { return strings.ToLower(s.ServiceName) })
+}
+
+// GetTotalsByUsers sums prices per user like GetTotalSubscriptionsCost,
+// serviceName is an ILIKE pattern there too. Users without subscriptions in
+// the range are missing from the result.
+func (repo *SubscriptionRepository) GetTotalsByUsers(
+	ctx context.Context,
+	userIDs []string,
+	dateFrom time.Time,
+	dateTo time.Time,
+	serviceName string,
+) (map[string]float64, error) {
+
+	query := `
+		SELECT user_id::text, COALESCE(SUM(price), 0)
+		FROM subscriptions
+		WHERE user_id = ANY($1)
+			AND start_date >= $2
+			AND start_date <= $3
+			AND deleted_at IS NULL
+			AND ($4 = '' OR service_name ILIKE $4)
+		GROUP BY user_id;
+	`
+
+	rows, err := repo.db.Query(ctx, query, userIDs, dateFrom, dateTo, serviceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate totals by users: %w", err)
+	}
+
+	return collectTotals(rows)
+}
+
+// GetTotalsByServices sums prices per service like GetTotalSubscriptionsCost
+func (repo *SubscriptionRepository) GetTotalsByServices(
+	ctx context.Context,
+	serviceNames []string,
+	dateFrom time.Time,
+	dateTo time.Time,
+	userID string,
+) (map[string]float64, error) {
+
+	query := `
+		SELECT lower(service_name), COALESCE(SUM(price), 0)
+		FROM subscriptions
+		WHERE lower(service_name) = ANY($1)
+			AND start_date >= $2
+			AND start_date <= $3
+			AND deleted_at IS NULL
+			AND ($4 = '' OR user_id::text = $4)
+		GROUP BY lower(service_name);
+	`
+
+	rows, err := repo.db.Query(ctx, query, lowerAll(serviceNames), dateFrom, dateTo, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate totals by services: %w", err)
+	}
+
+	return collectTotals(rows)
+}
+
+func groupSubscriptions(
+	rows pgx.Rows,
+	key func(s *models.Subscription) string,
+) (map[string][]models.Subscription, error) {
+
+	defer rows.Close()
+
+	grouped := make(map[string][]models.Subscription)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
+		}
+
+		k := key(s)
+		grouped[k] = append(grouped[k], *s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return grouped, nil
+}
+
+func collectTotals(rows pgx.Rows) (map[string]float64, error) {
+	defer rows.Close()
+
+	totals := make(map[string]float64)
+	for rows.Next() {
+		var (
+			key   string
+			total float64
+		)
+		if err := rows.Scan(&key, &total); err != nil {
+			return nil, fmt.Errorf("failed to scan total: %w", err)
+		}
+		totals[key] = total
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return totals, nil
+}
+
+func lowerAll(values []string) []string {
+	lowered := make([]string, len(values))
+	for i, v := range values {
+		lowered[i] = strings.ToLower(v)
+	}
+	return lowered
+}
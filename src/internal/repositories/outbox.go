@@ -0,0 +1,145 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/events"
+)
+
+const insertOutboxQuery = `
+	INSERT INTO outbox (aggregate_type, aggregate_id, event_type, payload)
+	VALUES ($1, $2, $3, $4);
+`
+
+// recordOutbox writes a domain event inside the caller's transaction. The
+// relay publishes it only after that transaction commits, so an event is
+// never lost to a crash between the write and the publish.
+func recordOutbox(
+	ctx context.Context,
+	tx pgx.Tx,
+	aggregateType string,
+	aggregateID string,
+	eventType string,
+	payload any,
+) error {
+
+	args, err := outboxArgs(aggregateType, aggregateID, eventType, payload)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, insertOutboxQuery, args...); err != nil {
+		return fmt.Errorf("failed to write outbox event: %w", err)
+	}
+
+	return nil
+}
+
+// outboxArgs builds the insertOutboxQuery arguments for one event
+func outboxArgs(aggregateType, aggregateID, eventType string, payload any) ([]any, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode outbox event: %w", err)
+	}
+
+	return []any{aggregateType, aggregateID, eventType, raw}, nil
+}
+
+type OutboxRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewOutboxRepository(db *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// Drain publishes up to limit of the oldest events one by one in id order.
+// Only one relay drains at a time, the others return 0 while the transaction
+// advisory lock is held. Published events are deleted; publishing stops at
+// the first failure so the failed event and everything after it are offered
+// again on the next call.
+func (repo *OutboxRepository) Drain(
+	ctx context.Context,
+	limit int,
+	publish func(ctx context.Context, e events.Event) error,
+) (int, error) {
+
+	query := `
+		SELECT id, aggregate_type, aggregate_id, event_type, payload, created_at
+		FROM outbox
+		ORDER BY id
+		LIMIT $1;
+	`
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var locked bool
+	err = tx.QueryRow(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('emob_outbox_relay'));`).Scan(&locked)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock outbox: %w", err)
+	}
+	if !locked {
+		return 0, nil
+	}
+
+	rows, err := tx.Query(ctx, query, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query outbox: %w", err)
+	}
+
+	batch := make([]events.Event, 0)
+	for rows.Next() {
+		var e events.Event
+		err := rows.Scan(
+			&e.ID,
+			&e.AggregateType,
+			&e.AggregateID,
+			&e.Type,
+			&e.Payload,
+			&e.CreatedAt,
+		)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan outbox event: %w", err)
+		}
+		batch = append(batch, e)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	published := make([]int64, 0, len(batch))
+	var publishErr error
+	for _, e := range batch {
+		if publishErr = publish(ctx, e); publishErr != nil {
+			publishErr = fmt.Errorf("failed to publish outbox event %d: %w", e.ID, publishErr)
+			break
+		}
+		published = append(published, e.ID)
+	}
+
+	if len(published) == 0 {
+		return 0, publishErr
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM outbox WHERE id = ANY($1);`, published); err != nil {
+		return 0, fmt.Errorf("failed to delete published events: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(published), publishErr
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+// CopySubscriptions bulk loads subscriptions with COPY, keeping their IDs,
+// dates and versions. Nothing is audited or published, it is meant for seed
+// data and fixtures rather than real changes.
+func (repo *SubscriptionRepository) CopySubscriptions(
+	ctx context.Context,
+	subscriptions iter.Seq[*models.Subscription],
+) (int64, error) {
+
+	next, stop := iter.Pull(subscriptions)
+	defer stop()
+
+	columns := []string{"id", "service_name", "price", "user_id", "start_date", "end_date", "created_at", "deleted_at", "version"}
+	source := pgx.CopyFromFunc(func() ([]any, error) {
+		s, ok := next()
+		if !ok {
+			return nil, nil
+		}
+		return []any{s.ID, s.ServiceName, s.Price, s.UserID, s.StartDate, nullDate(s.EndDate), s.CreatedAt, s.DeletedAt, s.Version}, nil
+	})
+
+	n, err := repo.db.CopyFrom(ctx, pgx.Identifier{"subscriptions"}, columns, source)
+	if err != nil {
+		return n, fmt.Errorf("failed to copy subscriptions: %w", err)
+	}
+
+	return n, nil
+}
+
+// DeleteAllSubscriptions empties the subscriptions table, soft-deleted rows
+// included. The audit log is append-only and keeps its history.
+func (repo *SubscriptionRepository) DeleteAllSubscriptions(
+	ctx context.Context,
+) error {
+
+	if _, err := repo.db.Exec(ctx, `TRUNCATE subscriptions;`); err != nil {
+		return fmt.Errorf("failed to delete subscriptions: %w", err)
+	}
+
+	return nil
+}
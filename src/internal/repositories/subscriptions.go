@@ -2,14 +2,67 @@ package repositories
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/nurkenspashev92/emob/internal/audit"
+	"github.com/nurkenspashev92/emob/internal/logging"
+	"github.com/nurkenspashev92/emob/internal/metrics"
 	"github.com/nurkenspashev92/emob/internal/models"
 )
 
+const subscriptionEntity = "subscription"
+
+// subscriptionColumns must stay in sync with scanSubscription
+const subscriptionColumns = `id, service_name, price, user_id, start_date, end_date, created_at, deleted_at, version`
+
+const (
+	insertSubscriptionQuery = `
+		INSERT INTO subscriptions (
+			service_name,
+			price,
+			user_id,
+			start_date,
+			end_date,
+			created_at
+		) VALUES ($1, $2, $3, $4, $5, NOW())
+		RETURNING ` + subscriptionColumns + `;
+	`
+
+	updateSubscriptionQuery = `
+		UPDATE subscriptions
+		SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5,
+			version = version + 1
+		WHERE id = $6
+		RETURNING ` + subscriptionColumns + `;
+	`
+
+	softDeleteSubscriptionQuery = `
+		UPDATE subscriptions
+		SET deleted_at = NOW(), version = version + 1
+		WHERE id = $1
+		RETURNING ` + subscriptionColumns + `;
+	`
+
+	lockSubscriptionQuery = `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE id = $1 AND deleted_at IS NULL
+		FOR UPDATE;
+	`
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrVersionMismatch      = errors.New("subscription version mismatch")
+	ErrInvalidSubscription  = errors.New("invalid subscription")
+)
+
 type SubscriptionRepository struct {
 	db *pgxpool.Pool
 }
@@ -18,27 +71,56 @@ func NewSubscriptionRepository(db *pgxpool.Pool) *SubscriptionRepository {
 	return &SubscriptionRepository{db: db}
 }
 
+func scanSubscription(row pgx.Row) (*models.Subscription, error) {
+	var s models.Subscription
+	var endDate *time.Time
+	err := row.Scan(
+		&s.ID,
+		&s.ServiceName,
+		&s.Price,
+		&s.UserID,
+		&s.StartDate,
+		&endDate,
+		&s.CreatedAt,
+		&s.DeletedAt,
+		&s.Version,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrSubscriptionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if endDate != nil {
+		s.EndDate = *endDate
+	}
+	return &s, nil
+}
+
+// nullDate stores the zero time, the end date of an open-ended
+// subscription, as NULL
+func nullDate(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
 func (repo *SubscriptionRepository) GetAllSubscriptions(
 	ctx context.Context,
-	limit int,
-	offset int,
+	filter models.SubscriptionFilter,
 ) ([]models.Subscription, error) {
 
 	query := `
-		SELECT 
-			id,
-			service_name,
-			price,
-			user_id,
-			start_date,
-			end_date,
-			created_at
+		SELECT ` + subscriptionColumns + `
 		FROM subscriptions
+		WHERE ($1 OR deleted_at IS NULL)
 		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2;
+		LIMIT $2 OFFSET $3;
 	`
 
-	rows, err := repo.db.Query(ctx, query, limit, offset)
+	rows, err := repo.db.Query(ctx, query, filter.IncludeDeleted, filter.Limit, filter.Offset)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query subscriptions: %w", err)
 	}
@@ -47,22 +129,12 @@ func (repo *SubscriptionRepository) GetAllSubscriptions(
 	subscriptions := make([]models.Subscription, 0)
 
 	for rows.Next() {
-		var s models.Subscription
-
-		err := rows.Scan(
-			&s.ID,
-			&s.ServiceName,
-			&s.Price,
-			&s.UserID,
-			&s.StartDate,
-			&s.EndDate,
-			&s.CreatedAt,
-		)
+		s, err := scanSubscription(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan subscription: %w", err)
 		}
 
-		subscriptions = append(subscriptions, s)
+		subscriptions = append(subscriptions, *s)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -72,142 +144,507 @@ func (repo *SubscriptionRepository) GetAllSubscriptions(
 	return subscriptions, nil
 }
 
-func (repo *SubscriptionRepository) CreateSubscriptions(
+// GetUserSubscriptions returns every live subscription of a user ordered by
+// start date.
+func (repo *SubscriptionRepository) GetUserSubscriptions(
 	ctx context.Context,
-	subscriptionBody models.CreateSubscription,
-) (*models.Subscription, error) {
+	userID string,
+) ([]models.Subscription, error) {
 
-	startDate, err := time.Parse("2006-01-02", subscriptionBody.StartDate)
+	query := `
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE user_id = $1 AND deleted_at IS NULL
+		ORDER BY start_date, id;
+	`
+
+	rows, err := repo.db.Query(ctx, query, userID)
 	if err != nil {
-		return nil, fmt.Errorf("invalid start date: %w", err)
+		return nil, fmt.Errorf("failed to query user subscriptions: %w", err)
 	}
+	defer rows.Close()
+
+	subscriptions := make([]models.Subscription, 0)
 
-	var endDate time.Time
-	if subscriptionBody.EndDate != "" {
-		endDate, err = time.Parse("2006-01-02", subscriptionBody.EndDate)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
 		if err != nil {
-			return nil, fmt.Errorf("invalid end date: %w", err)
+			return nil, fmt.Errorf("failed to scan subscription: %w", err)
 		}
+
+		subscriptions = append(subscriptions, *s)
 	}
 
-	query := `
-		INSERT INTO subscriptions (
-			service_name,
-			price,
-			user_id,
-			start_date,
-			end_date,
-			created_at
-		) VALUES ($1, $2, $3, $4, $5, NOW())
-		RETURNING id, service_name, price, user_id, start_date, end_date, created_at;
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return subscriptions, nil
+}
+
+// exportFetchSize is how many rows are pulled from the cursor per round trip
+const exportFetchSize = 1000
+
+// StreamSubscriptions walks the filtered list through a server-side cursor and
+// calls fn for each row, so arbitrarily large exports use constant memory.
+// A zero filter.Limit means no limit.
+func (repo *SubscriptionRepository) StreamSubscriptions(
+	ctx context.Context,
+	filter models.SubscriptionFilter,
+	fn func(s *models.Subscription) error,
+) error {
+
+	tx, err := repo.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	declare := `
+		DECLARE subscriptions_export NO SCROLL CURSOR FOR
+		SELECT ` + subscriptionColumns + `
+		FROM subscriptions
+		WHERE ($1::boolean OR deleted_at IS NULL)
+		ORDER BY created_at DESC
+		LIMIT NULLIF($2::integer, 0) OFFSET $3::integer;
 	`
 
-	row := repo.db.QueryRow(ctx, query,
+	if _, err := tx.Exec(ctx, declare, filter.IncludeDeleted, filter.Limit, filter.Offset); err != nil {
+		return fmt.Errorf("failed to declare export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH %d FROM subscriptions_export;`, exportFetchSize)
+	for {
+		rows, err := tx.Query(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch subscriptions: %w", err)
+		}
+
+		fetched := 0
+		for rows.Next() {
+			s, err := scanSubscription(rows)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan subscription: %w", err)
+			}
+
+			if err := fn(s); err != nil {
+				rows.Close()
+				return err
+			}
+			fetched++
+		}
+		rows.Close()
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows error: %w", err)
+		}
+		if fetched < exportFetchSize {
+			return nil
+		}
+	}
+}
+
+func (repo *SubscriptionRepository) CreateSubscriptions(
+	ctx context.Context,
+	subscriptionBody models.CreateSubscription,
+) (*models.Subscription, error) {
+
+	startDate, endDate, err := parseSubscriptionDates(subscriptionBody)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	s, err := scanSubscription(tx.QueryRow(ctx, insertSubscriptionQuery,
 		subscriptionBody.ServiceName,
 		subscriptionBody.Price,
 		subscriptionBody.UserID,
 		startDate,
-		endDate,
-	)
-
-	var s models.Subscription
-	if err := row.Scan(
-		&s.ID,
-		&s.ServiceName,
-		&s.Price,
-		&s.UserID,
-		&s.StartDate,
-		&s.EndDate,
-		&s.CreatedAt,
-	); err != nil {
+		nullDate(endDate),
+	))
+	if err != nil {
 		return nil, fmt.Errorf("failed to scan created subscription: %w", err)
 	}
 
-	return &s, nil
+	if err := recordSubscriptionChange(ctx, tx, audit.ActionCreate, nil, s); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionCreate, 1)
+
+	return s, nil
+}
+
+func parseSubscriptionDates(body models.CreateSubscription) (startDate, endDate time.Time, err error) {
+	startDate, err = time.Parse("2006-01-02", body.StartDate)
+	if err != nil {
+		return startDate, endDate, fmt.Errorf("%w: invalid start date: %v", ErrInvalidSubscription, err)
+	}
+
+	if body.EndDate != "" {
+		endDate, err = time.Parse("2006-01-02", body.EndDate)
+		if err != nil {
+			return startDate, endDate, fmt.Errorf("%w: invalid end date: %v", ErrInvalidSubscription, err)
+		}
+	}
+
+	return startDate, endDate, nil
 }
 
 func (repo *SubscriptionRepository) GetSubscriptionByID(
 	ctx context.Context,
 	id string,
+	includeDeleted bool,
 ) (*models.Subscription, error) {
 
 	query := `
-		SELECT id, service_name, price, user_id, start_date, end_date, created_at
+		SELECT ` + subscriptionColumns + `
 		FROM subscriptions
-		WHERE id = $1;
+		WHERE id = $1 AND ($2 OR deleted_at IS NULL);
 	`
 
-	row := repo.db.QueryRow(ctx, query, id)
-
-	var s models.Subscription
-	if err := row.Scan(
-		&s.ID,
-		&s.ServiceName,
-		&s.Price,
-		&s.UserID,
-		&s.StartDate,
-		&s.EndDate,
-		&s.CreatedAt,
-	); err != nil {
+	s, err := scanSubscription(repo.db.QueryRow(ctx, query, id, includeDeleted))
+	if err != nil {
 		return nil, fmt.Errorf("failed to get subscription: %w", err)
 	}
 
-	return &s, nil
+	return s, nil
 }
 
+// UpdateSubscription replaces all fields of a live subscription. When ifMatch
+// is not empty the current version must be one of the listed versions.
 func (repo *SubscriptionRepository) UpdateSubscription(
 	ctx context.Context,
 	id string,
 	body models.CreateSubscription,
+	ifMatch []int,
 ) (*models.Subscription, error) {
 
-	query := `
-		UPDATE subscriptions
-		SET service_name = $1, price = $2, user_id = $3, start_date = $4, end_date = $5
-		WHERE id = $6
-		RETURNING id, service_name, price, user_id, start_date, end_date, created_at;
-	`
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := updateLocked(ctx, tx, before, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionUpdate, 1)
+
+	return s, nil
+}
+
+// PatchSubscription updates only the fields present in the body
+func (repo *SubscriptionRepository) PatchSubscription(
+	ctx context.Context,
+	id string,
+	patch models.PatchSubscription,
+	ifMatch []int,
+) (*models.Subscription, error) {
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, ifMatch)
+	if err != nil {
+		return nil, err
+	}
 
-	row := repo.db.QueryRow(ctx, query,
+	body := models.CreateSubscription{
+		ServiceName: before.ServiceName,
+		Price:       before.Price,
+		UserID:      before.UserID,
+		StartDate:   before.StartDate.Format("2006-01-02"),
+	}
+	if !before.EndDate.IsZero() {
+		body.EndDate = before.EndDate.Format("2006-01-02")
+	}
+	if patch.ServiceName != nil {
+		body.ServiceName = *patch.ServiceName
+	}
+	if patch.Price != nil {
+		body.Price = *patch.Price
+	}
+	if patch.UserID != nil {
+		body.UserID = *patch.UserID
+	}
+	if patch.StartDate != nil {
+		body.StartDate = *patch.StartDate
+	}
+	if patch.EndDate != nil {
+		body.EndDate = *patch.EndDate
+	}
+
+	// The patch alone can look fine while the merged row does not, e.g. an
+	// end_date before the stored start_date
+	if err := body.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSubscription, err)
+	}
+
+	s, err := updateLocked(ctx, tx, before, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionUpdate, 1)
+
+	return s, nil
+}
+
+// updateLocked writes body over a row previously locked with lockSubscription
+// and bumps its version.
+func updateLocked(
+	ctx context.Context,
+	tx pgx.Tx,
+	before *models.Subscription,
+	body models.CreateSubscription,
+) (*models.Subscription, error) {
+
+	startDate, endDate, err := parseSubscriptionDates(body)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := scanSubscription(tx.QueryRow(ctx, updateSubscriptionQuery,
 		body.ServiceName,
 		body.Price,
 		body.UserID,
-		body.StartDate,
-		body.EndDate,
-		id,
-	)
-
-	var s models.Subscription
-	if err := row.Scan(
-		&s.ID,
-		&s.ServiceName,
-		&s.Price,
-		&s.UserID,
-		&s.StartDate,
-		&s.EndDate,
-		&s.CreatedAt,
-	); err != nil {
+		startDate,
+		nullDate(endDate),
+		before.ID,
+	))
+	if err != nil {
 		return nil, fmt.Errorf("failed to scan updated subscription: %w", err)
 	}
 
-	return &s, nil
+	if err := recordSubscriptionChange(ctx, tx, audit.ActionUpdate, before, s); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
 
+// DeleteSubscription marks the subscription as deleted. The row stays in the
+// table until the retention purge removes it.
 func (repo *SubscriptionRepository) DeleteSubscription(
 	ctx context.Context,
 	id string,
+	ifMatch []int,
 ) error {
 
-	query := `DELETE FROM subscriptions WHERE id = $1;`
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := lockSubscription(ctx, tx, id, ifMatch)
+	if err != nil {
+		return err
+	}
 
-	_, err := repo.db.Exec(ctx, query, id)
+	s, err := scanSubscription(tx.QueryRow(ctx, softDeleteSubscriptionQuery, id))
 	if err != nil {
 		return fmt.Errorf("failed to delete subscription: %w", err)
 	}
 
+	if err := recordSubscriptionChange(ctx, tx, audit.ActionDelete, before, s); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionDelete, 1)
+
 	return nil
 }
 
+func (repo *SubscriptionRepository) RestoreSubscription(
+	ctx context.Context,
+	id string,
+) (*models.Subscription, error) {
+
+	query := `
+		UPDATE subscriptions
+		SET deleted_at = NULL, version = version + 1
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		RETURNING ` + subscriptionColumns + `;
+	`
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := scanSubscription(tx.QueryRow(ctx, `
+		SELECT `+subscriptionColumns+`
+		FROM subscriptions
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE;
+	`, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock deleted subscription: %w", err)
+	}
+
+	s, err := scanSubscription(tx.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore subscription: %w", err)
+	}
+
+	if err := recordSubscriptionChange(ctx, tx, audit.ActionRestore, before, s); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionRestore, 1)
+
+	return s, nil
+}
+
+// PurgeDeletedSubscriptions permanently removes rows soft-deleted before the
+// given time and returns how many were removed.
+func (repo *SubscriptionRepository) PurgeDeletedSubscriptions(
+	ctx context.Context,
+	deletedBefore time.Time,
+) (int, error) {
+
+	query := `
+		DELETE FROM subscriptions
+		WHERE deleted_at IS NOT NULL AND deleted_at < $1
+		RETURNING ` + subscriptionColumns + `;
+	`
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, deletedBefore)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge subscriptions: %w", err)
+	}
+
+	purged := make([]models.Subscription, 0)
+	for rows.Next() {
+		s, err := scanSubscription(rows)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan purged subscription: %w", err)
+		}
+		purged = append(purged, *s)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	for i := range purged {
+		if err := recordSubscriptionChange(ctx, tx, audit.ActionPurge, &purged[i], nil); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	metrics.SubscriptionChanged(audit.ActionPurge, len(purged))
+
+	return len(purged), nil
+}
+
+// subscriptionEvents maps audit actions to the domain event they publish
+var subscriptionEvents = map[string]string{
+	audit.ActionCreate:  models.EventSubscriptionCreated,
+	audit.ActionUpdate:  models.EventSubscriptionUpdated,
+	audit.ActionDelete:  models.EventSubscriptionDeleted,
+	audit.ActionRestore: models.EventSubscriptionRestored,
+	audit.ActionPurge:   models.EventSubscriptionPurged,
+}
+
+// recordSubscriptionChange writes everything a mutation leaves behind in the
+// same transaction: the audit event, the outbox event and the webhook
+// deliveries. Purges are not sent to webhooks.
+func recordSubscriptionChange(
+	ctx context.Context,
+	tx pgx.Tx,
+	action string,
+	before *models.Subscription,
+	after *models.Subscription,
+) error {
+
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+	event := subscriptionEvents[action]
+
+	if err := recordAudit(ctx, tx, subscriptionEntity, subject.ID, action, before, after); err != nil {
+		return err
+	}
+
+	if err := recordOutbox(ctx, tx, subscriptionEntity, subject.ID, event, subject); err != nil {
+		return err
+	}
+
+	if action == audit.ActionPurge {
+		return nil
+	}
+	return enqueueWebhookEvent(ctx, tx, event, subject, "")
+}
+
+// lockSubscription reads the current live row and holds a lock on it until
+// the transaction ends, giving a consistent "before" snapshot for auditing.
+// A non-empty ifMatch list makes it fail unless the row is at one of those versions.
+func lockSubscription(ctx context.Context, tx pgx.Tx, id string, ifMatch []int) (*models.Subscription, error) {
+	s, err := scanSubscription(tx.QueryRow(ctx, lockSubscriptionQuery, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock subscription: %w", err)
+	}
+
+	if len(ifMatch) > 0 && !slices.Contains(ifMatch, s.Version) {
+		return nil, ErrVersionMismatch
+	}
+
+	return s, nil
+}
+
 func (repo *SubscriptionRepository) GetTotalSubscriptionsCost(
 	ctx context.Context,
 	dateFrom string,
@@ -221,6 +658,7 @@ func (repo *SubscriptionRepository) GetTotalSubscriptionsCost(
 		FROM subscriptions
 		WHERE start_date >= $1
 		  AND start_date <= $2
+		  AND deleted_at IS NULL
 	`
 
 	args := []interface{}{dateFrom, dateTo}
@@ -237,7 +675,8 @@ func (repo *SubscriptionRepository) GetTotalSubscriptionsCost(
 		args = append(args, serviceName)
 	}
 
-	fmt.Println(query)
+	logging.FromContext(ctx).Debug("Calculating total subscriptions cost", "query", query, "args", args)
+
 	var total float64
 	err := repo.db.QueryRow(ctx, query, args...).Scan(&total)
 	if err != nil {
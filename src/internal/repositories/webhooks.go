@@ -0,0 +1,542 @@
+package repositories
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+const webhookColumns = `id, url, events, active, created_at, updated_at`
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt_at,
+	last_status_code, last_error, delivered_at, created_at`
+
+// insertWebhookDeliveryQuery fans one event out to every active webhook
+// subscribed to it. Deliveries with a dedupe key are only queued once.
+const insertWebhookDeliveryQuery = `
+	INSERT INTO webhook_deliveries (webhook_id, event, payload, dedupe_key)
+	SELECT id, $1::text, $2::jsonb, NULLIF($3::text, '')
+	FROM webhooks
+	WHERE active AND $1::text = ANY(events)
+	ON CONFLICT DO NOTHING;
+`
+
+// PendingDelivery is a delivery claimed by the worker, joined with the
+// receiver it is addressed to.
+type PendingDelivery struct {
+	ID       string
+	Event    string
+	Payload  []byte
+	Attempts int
+	URL      string
+	Secret   string
+}
+
+// enqueueWebhookEvent queues an event inside the caller's transaction so it is
+// only delivered if the mutation it describes commits.
+func enqueueWebhookEvent(
+	ctx context.Context,
+	tx pgx.Tx,
+	event string,
+	data any,
+	dedupeKey string,
+) error {
+
+	args, err := webhookEventArgs(event, data, dedupeKey)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, insertWebhookDeliveryQuery, args...); err != nil {
+		return fmt.Errorf("failed to enqueue webhook event: %w", err)
+	}
+
+	return nil
+}
+
+// webhookEventArgs builds the insertWebhookDeliveryQuery arguments for one event
+func webhookEventArgs(event string, data any, dedupeKey string) ([]any, error) {
+	payload, err := json.Marshal(models.WebhookEvent{
+		ID:        uuid.NewString(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode webhook event: %w", err)
+	}
+
+	return []any{event, payload, dedupeKey}, nil
+}
+
+type WebhookRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWebhookRepository(db *pgxpool.Pool) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+func scanWebhook(row pgx.Row) (*models.Webhook, error) {
+	var w models.Webhook
+	err := row.Scan(
+		&w.ID,
+		&w.URL,
+		&w.Events,
+		&w.Active,
+		&w.CreatedAt,
+		&w.UpdatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &w, nil
+}
+
+func scanDelivery(row pgx.Row) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	err := row.Scan(
+		&d.ID,
+		&d.WebhookID,
+		&d.Event,
+		&d.Payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &d, nil
+}
+
+// CreateWebhook stores a webhook. The secret is only returned here.
+func (repo *WebhookRepository) CreateWebhook(
+	ctx context.Context,
+	body models.CreateWebhook,
+) (*models.Webhook, error) {
+
+	query := `
+		INSERT INTO webhooks (url, secret, events, active)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookColumns + `;
+	`
+
+	w, err := scanWebhook(repo.db.QueryRow(ctx, query, body.URL, body.Secret, body.Events, *body.Active))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	w.Secret = body.Secret
+	return w, nil
+}
+
+func (repo *WebhookRepository) GetWebhooks(ctx context.Context) ([]models.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id;`
+
+	rows, err := repo.db.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, *w)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+func (repo *WebhookRepository) GetWebhookByID(
+	ctx context.Context,
+	id string,
+) (*models.Webhook, error) {
+
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1;`
+
+	w, err := scanWebhook(repo.db.QueryRow(ctx, query, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return w, nil
+}
+
+// UpdateWebhook replaces url, events and active. The secret is rotated only
+// when the body carries a new one.
+func (repo *WebhookRepository) UpdateWebhook(
+	ctx context.Context,
+	id string,
+	body models.CreateWebhook,
+) (*models.Webhook, error) {
+
+	query := `
+		UPDATE webhooks
+		SET url = $1, events = $2, active = $3,
+			secret = COALESCE(NULLIF($4, ''), secret),
+			updated_at = NOW()
+		WHERE id = $5
+		RETURNING ` + webhookColumns + `;
+	`
+
+	w, err := scanWebhook(repo.db.QueryRow(ctx, query, body.URL, body.Events, *body.Active, body.Secret, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	w.Secret = body.Secret
+	return w, nil
+}
+
+// DeleteWebhook removes the webhook together with its delivery log
+func (repo *WebhookRepository) DeleteWebhook(ctx context.Context, id string) error {
+	tag, err := repo.db.Exec(ctx, `DELETE FROM webhooks WHERE id = $1;`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+func (repo *WebhookRepository) GetDeliveries(
+	ctx context.Context,
+	webhookID string,
+	status string,
+	limit int,
+	offset int,
+) ([]models.WebhookDelivery, error) {
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC, id
+		LIMIT $3 OFFSET $4;
+	`
+
+	rows, err := repo.db.Query(ctx, query, webhookID, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, *d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// GetDelivery returns a delivery together with the log of every HTTP attempt
+func (repo *WebhookRepository) GetDelivery(
+	ctx context.Context,
+	webhookID string,
+	deliveryID string,
+) (*models.WebhookDelivery, error) {
+
+	query := `
+		SELECT ` + deliveryColumns + `
+		FROM webhook_deliveries
+		WHERE id = $1 AND webhook_id = $2;
+	`
+
+	d, err := scanDelivery(repo.db.QueryRow(ctx, query, deliveryID, webhookID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %w", err)
+	}
+
+	rows, err := repo.db.Query(ctx, `
+		SELECT status_code, error, duration_ms, created_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY id;
+	`, deliveryID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery attempts: %w", err)
+	}
+	defer rows.Close()
+
+	d.AttemptLog = make([]models.WebhookDeliveryAttempt, 0)
+	for rows.Next() {
+		var a models.WebhookDeliveryAttempt
+		if err := rows.Scan(&a.StatusCode, &a.Error, &a.DurationMs, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery attempt: %w", err)
+		}
+		d.AttemptLog = append(d.AttemptLog, a)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return d, nil
+}
+
+// Redeliver puts a delivery back in the queue with a fresh retry budget,
+// whatever state it is in. The attempt log is kept.
+func (repo *WebhookRepository) Redeliver(
+	ctx context.Context,
+	webhookID string,
+	deliveryID string,
+) (*models.WebhookDelivery, error) {
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), delivered_at = NULL
+		WHERE id = $1 AND webhook_id = $2
+		RETURNING ` + deliveryColumns + `;
+	`
+
+	d, err := scanDelivery(repo.db.QueryRow(ctx, query, deliveryID, webhookID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to redeliver webhook delivery: %w", err)
+	}
+
+	return d, nil
+}
+
+// ClaimDueDeliveries takes up to limit pending deliveries whose time has come.
+// Claiming counts as an attempt and pushes next_attempt_at out by lease, so a
+// worker that dies mid-request only delays the delivery. SKIP LOCKED lets
+// several instances share the queue.
+func (repo *WebhookRepository) ClaimDueDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]PendingDelivery, error) {
+
+	query := `
+		UPDATE webhook_deliveries d
+		SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id
+			FROM webhook_deliveries dd
+			JOIN webhooks ww ON ww.id = dd.webhook_id AND ww.active
+			WHERE dd.status = 'pending' AND dd.next_attempt_at <= NOW()
+			ORDER BY dd.next_attempt_at
+			LIMIT $1
+			FOR UPDATE OF dd SKIP LOCKED
+		)
+		RETURNING d.id, d.event, d.payload, d.attempts, w.url, w.secret;
+	`
+
+	rows, err := repo.db.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	claimed := make([]PendingDelivery, 0)
+	for rows.Next() {
+		var d PendingDelivery
+		if err := rows.Scan(&d.ID, &d.Event, &d.Payload, &d.Attempts, &d.URL, &d.Secret); err != nil {
+			return nil, fmt.Errorf("failed to scan claimed delivery: %w", err)
+		}
+		claimed = append(claimed, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return claimed, nil
+}
+
+// RecordAttempt stores the outcome of one HTTP call and moves the delivery to
+// its next state: succeeded, pending again at retryAt, or dead when retryAt
+// is nil.
+func (repo *WebhookRepository) RecordAttempt(
+	ctx context.Context,
+	deliveryID string,
+	statusCode int,
+	attemptErr error,
+	duration time.Duration,
+	retryAt *time.Time,
+) error {
+
+	var code *int
+	if statusCode != 0 {
+		code = &statusCode
+	}
+
+	var message *string
+	if attemptErr != nil {
+		m := attemptErr.Error()
+		message = &m
+	}
+
+	status := models.DeliverySucceeded
+	switch {
+	case attemptErr != nil && retryAt != nil:
+		status = models.DeliveryPending
+	case attemptErr != nil:
+		status = models.DeliveryDead
+	}
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2,
+			next_attempt_at = COALESCE($3, next_attempt_at),
+			last_status_code = $4,
+			last_error = $5,
+			delivered_at = CASE WHEN $2 = 'succeeded' THEN NOW() END
+		WHERE id = $1;
+	`, deliveryID, status, retryAt, code, message)
+	if err != nil {
+		return fmt.Errorf("failed to update webhook delivery: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms)
+		VALUES ($1, $2, $3, $4);
+	`, deliveryID, code, message, duration.Milliseconds())
+	if err != nil {
+		return fmt.Errorf("failed to log delivery attempt: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// EnqueueUpcomingRenewals queues a renewal.upcoming event for every live
+// subscription renewing between from and to, inclusive. A subscription renews
+// on the day of month it started, or on the last day of shorter months. Each
+// renewal is only announced once per webhook.
+func (repo *WebhookRepository) EnqueueUpcomingRenewals(
+	ctx context.Context,
+	from time.Time,
+	to time.Time,
+) (int, error) {
+
+	query := `
+		SELECT ` + subscriptionColumns + `, d::date
+		FROM subscriptions
+		CROSS JOIN generate_series($1::date, $2::date, interval '1 day') AS d
+		WHERE deleted_at IS NULL
+			AND d > start_date
+			AND (end_date IS NULL OR d <= end_date)
+			AND (
+				EXTRACT(DAY FROM d) = EXTRACT(DAY FROM start_date)
+				OR (
+					EXTRACT(DAY FROM start_date) > EXTRACT(DAY FROM d)
+					AND d = date_trunc('month', d) + interval '1 month - 1 day'
+				)
+			)
+		ORDER BY d, id;
+	`
+
+	tx, err := repo.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, query, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query upcoming renewals: %w", err)
+	}
+
+	notices := make([]models.RenewalNotice, 0)
+	for rows.Next() {
+		var renewal time.Time
+		s, err := scanSubscription(withExtraColumns(rows, &renewal))
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan upcoming renewal: %w", err)
+		}
+		notices = append(notices, models.RenewalNotice{
+			RenewalDate:  renewal.Format(models.DateLayout),
+			Subscription: *s,
+		})
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("rows error: %w", err)
+	}
+
+	for _, n := range notices {
+		key := "renewal:" + n.Subscription.ID + ":" + n.RenewalDate
+		if err := enqueueWebhookEvent(ctx, tx, models.EventRenewalUpcoming, n, key); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return len(notices), nil
+}
+
+// extraColumnsRow lets scanSubscription read rows that carry more columns
+// after subscriptionColumns.
+type extraColumnsRow struct {
+	row   pgx.Row
+	extra []any
+}
+
+func withExtraColumns(row pgx.Row, extra ...any) pgx.Row {
+	return extraColumnsRow{row: row, extra: extra}
+}
+
+func (r extraColumnsRow) Scan(dest ...any) error {
+	return r.row.Scan(append(dest, r.extra...)...)
+}
@@ -0,0 +1,117 @@
+package seed
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.yaml.in/yaml/v3"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// Fixtures are small hand-written data sets with fixed IDs, so integration
+// tests can load one and assert on known rows and totals. Each file in
+// fixtures/ describes what it is for.
+//
+//go:embed fixtures/*.yaml
+var fixtures embed.FS
+
+type fixtureFile struct {
+	Subscriptions []struct {
+		ID          string     `yaml:"id"`
+		ServiceName string     `yaml:"service_name"`
+		Price       int        `yaml:"price"`
+		UserID      string     `yaml:"user_id"`
+		StartDate   string     `yaml:"start_date"`
+		EndDate     string     `yaml:"end_date"`
+		DeletedAt   *time.Time `yaml:"deleted_at"`
+	} `yaml:"subscriptions"`
+}
+
+// FixtureNames lists the fixture sets in alphabetical order
+func FixtureNames() []string {
+	entries, _ := fs.ReadDir(fixtures, "fixtures")
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), path.Ext(e.Name())))
+	}
+	return names
+}
+
+// Fixture returns the subscriptions of a named set. Rows are created at the
+// start of their start date.
+func Fixture(name string) ([]models.Subscription, error) {
+	if !slices.Contains(FixtureNames(), name) {
+		return nil, fmt.Errorf("unknown fixture %q, use one of %s", name, strings.Join(FixtureNames(), ", "))
+	}
+
+	data, err := fixtures.ReadFile("fixtures/" + name + ".yaml")
+	if err != nil {
+		return nil, err
+	}
+
+	var file fixtureFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", name, err)
+	}
+
+	list := make([]models.Subscription, 0, len(file.Subscriptions))
+	for i, row := range file.Subscriptions {
+		body := models.CreateSubscription{
+			ServiceName: row.ServiceName,
+			Price:       row.Price,
+			UserID:      row.UserID,
+			StartDate:   row.StartDate,
+			EndDate:     row.EndDate,
+		}
+		if err := body.Validate(); err != nil {
+			return nil, fmt.Errorf("fixture %s row %d: %w", name, i+1, err)
+		}
+		if _, err := uuid.Parse(row.ID); err != nil {
+			return nil, fmt.Errorf("fixture %s row %d: id must be a valid UUID", name, i+1)
+		}
+
+		start, _ := time.Parse(models.DateLayout, row.StartDate)
+		end, _ := time.Parse(models.DateLayout, row.EndDate)
+		list = append(list, models.Subscription{
+			ID:          row.ID,
+			ServiceName: row.ServiceName,
+			Price:       row.Price,
+			UserID:      row.UserID,
+			StartDate:   start,
+			EndDate:     end,
+			CreatedAt:   start,
+			DeletedAt:   row.DeletedAt,
+			Version:     1,
+		})
+	}
+	return list, nil
+}
+
+// LoadFixture copies a named set into the subscriptions table
+func LoadFixture(ctx context.Context, db *pgxpool.Pool, name string) (int64, error) {
+	list, err := Fixture(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return repositories.NewSubscriptionRepository(db).CopySubscriptions(ctx, slices.Values(pointers(list)))
+}
+
+func pointers(list []models.Subscription) []*models.Subscription {
+	ptrs := make([]*models.Subscription, len(list))
+	for i := range list {
+		ptrs[i] = &list[i]
+	}
+	return ptrs
+}
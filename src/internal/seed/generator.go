@@ -0,0 +1,215 @@
+package seed
+
+import (
+	"encoding/binary"
+	"fmt"
+	"iter"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+)
+
+const (
+	PricesCatalog   = "catalog"
+	PricesUniform   = "uniform"
+	PricesLognormal = "lognormal"
+)
+
+// Options shape a generated data set. The same options, seed included,
+// always produce the same rows.
+type Options struct {
+	Subscriptions int
+	Users         int
+	// Start dates are spread over From to To
+	From time.Time
+	To   time.Time
+	// Churn is the share of subscriptions cancelled after a few months,
+	// the rest stay paid for a year past To
+	Churn float64
+	// Deleted is the share of subscriptions that are soft-deleted
+	Deleted float64
+	// Prices is catalog, using the tiers of each service, or uniform or
+	// lognormal within about PriceMin to PriceMax
+	Prices   string
+	PriceMin int
+	PriceMax int
+	Seed     uint64
+}
+
+func (o Options) Validate() error {
+	var errs models.ValidationErrors
+
+	if o.Subscriptions < 0 {
+		errs = append(errs, "subscription count must not be negative")
+	}
+	if o.Users <= 0 {
+		errs = append(errs, "user count must be positive")
+	}
+	if o.To.Before(o.From) {
+		errs = append(errs, "the period must not end before it starts")
+	}
+	if o.Churn < 0 || o.Churn > 1 {
+		errs = append(errs, "churn must be between 0 and 1")
+	}
+	if o.Deleted < 0 || o.Deleted > 1 {
+		errs = append(errs, "deleted share must be between 0 and 1")
+	}
+	switch o.Prices {
+	case PricesCatalog:
+	case PricesUniform, PricesLognormal:
+		if o.PriceMin <= 0 || o.PriceMax < o.PriceMin {
+			errs = append(errs, "prices need 0 < min <= max")
+		}
+	default:
+		errs = append(errs, fmt.Sprintf("unknown price distribution %q, use catalog, uniform or lognormal", o.Prices))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Generate returns the subscriptions lazily so large data sets can be
+// streamed into COPY.
+func Generate(o Options) iter.Seq[*models.Subscription] {
+	return func(yield func(*models.Subscription) bool) {
+		var key [32]byte
+		binary.LittleEndian.PutUint64(key[:], o.Seed)
+		// ChaCha8 also serves as the reader for the UUIDs
+		src := rand.NewChaCha8(key)
+		g := &generator{opts: o, rng: rand.New(src), ids: src}
+
+		users := make([]string, o.Users)
+		for i := range users {
+			users[i] = g.uuid()
+		}
+
+		days := int(o.To.Sub(o.From).Hours()/24) + 1
+		for range o.Subscriptions {
+			if !yield(g.subscription(users, days)) {
+				return
+			}
+		}
+	}
+}
+
+type generator struct {
+	opts Options
+	rng  *rand.Rand
+	ids  *rand.ChaCha8
+}
+
+func (g *generator) subscription(users []string, days int) *models.Subscription {
+	service := g.service()
+	start := g.opts.From.AddDate(0, 0, g.rng.IntN(days))
+
+	// Churned subscriptions last a geometric number of months with a mean
+	// of six, the others are paid through a year after the period
+	var end time.Time
+	if g.rng.Float64() < g.opts.Churn {
+		months := 1
+		for g.rng.Float64() > 1.0/6 {
+			months++
+		}
+		end = start.AddDate(0, months, -1)
+	} else {
+		end = g.opts.To.AddDate(1, 0, 0)
+	}
+
+	created := start.Add(time.Duration(g.rng.IntN(24*60*60)) * time.Second)
+
+	var deletedAt *time.Time
+	if g.rng.Float64() < g.opts.Deleted {
+		// Deleted within a month of creation, but not after the period
+		window := min(30*24*time.Hour, g.opts.To.AddDate(0, 0, 1).Sub(created))
+		d := created
+		if window > 0 {
+			d = created.Add(time.Duration(g.rng.Int64N(int64(window))))
+		}
+		deletedAt = &d
+	}
+
+	return &models.Subscription{
+		ID:          g.uuid(),
+		ServiceName: service.Name,
+		Price:       g.price(service),
+		UserID:      users[g.user(len(users))],
+		StartDate:   start,
+		EndDate:     end,
+		CreatedAt:   created,
+		DeletedAt:   deletedAt,
+		Version:     1,
+	}
+}
+
+// user favours the first users so a few have many subscriptions and most
+// have one or two
+func (g *generator) user(n int) int {
+	u := g.rng.Float64()
+	return int(float64(n) * u * u)
+}
+
+func (g *generator) service() Service {
+	n := g.rng.IntN(totalWeight)
+	for _, s := range Services {
+		if n < s.Weight {
+			return s
+		}
+		n -= s.Weight
+	}
+	return Services[len(Services)-1]
+}
+
+func (g *generator) price(s Service) int {
+	lo, hi := float64(g.opts.PriceMin), float64(g.opts.PriceMax)
+
+	switch g.opts.Prices {
+	case PricesUniform:
+		return roundPrice(lo + g.rng.Float64()*(hi-lo))
+	case PricesLognormal:
+		// The bounds sit about two standard deviations from the median
+		median := math.Sqrt(lo * hi)
+		sigma := math.Log(hi/lo) / 4
+		p := median * math.Exp(sigma*g.rng.NormFloat64())
+		return roundPrice(min(max(p, lo), hi))
+	}
+
+	// Cheaper tiers are the more popular ones
+	weights := []int{6, 3, 1}
+	n := g.rng.IntN(sum(weights[:len(s.Prices)]))
+	for i, w := range weights[:len(s.Prices)] {
+		if n < w {
+			return s.Prices[i]
+		}
+		n -= w
+	}
+	return s.Prices[0]
+}
+
+func (g *generator) uuid() string {
+	id, err := uuid.NewRandomFromReader(g.ids)
+	if err != nil {
+		// ChaCha8 never fails to read
+		panic(err)
+	}
+	return id.String()
+}
+
+// roundPrice makes prices end in 9 like real ones
+func roundPrice(p float64) int {
+	n := int(p)
+	return max(n-n%10+9, 9)
+}
+
+func sum(values []int) int {
+	total := 0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
@@ -0,0 +1,35 @@
+package seed
+
+// Service is a subscription service with up to three monthly price tiers
+type Service struct {
+	Name string
+	// Weight is how popular the service is relative to the others
+	Weight int
+	Prices []int
+}
+
+// Services are the ones generated subscriptions pick from
+var Services = []Service{
+	{Name: "Yandex Plus", Weight: 20, Prices: []int{399, 649, 999}},
+	{Name: "Kinopoisk", Weight: 8, Prices: []int{299, 599}},
+	{Name: "Okko", Weight: 6, Prices: []int{399, 699, 999}},
+	{Name: "ivi", Weight: 6, Prices: []int{399, 599}},
+	{Name: "Wink", Weight: 4, Prices: []int{349, 649}},
+	{Name: "Start", Weight: 3, Prices: []int{299, 499}},
+	{Name: "VK Music", Weight: 12, Prices: []int{199, 299}},
+	{Name: "Telegram Premium", Weight: 10, Prices: []int{299}},
+	{Name: "Netflix", Weight: 5, Prices: []int{799, 999, 1499}},
+	{Name: "Spotify", Weight: 5, Prices: []int{169, 269, 299}},
+	{Name: "YouTube Premium", Weight: 7, Prices: []int{199, 299}},
+	{Name: "Apple Music", Weight: 4, Prices: []int{169, 269}},
+	{Name: "ChatGPT Plus", Weight: 4, Prices: []int{1999}},
+	{Name: "Storage Cloud", Weight: 6, Prices: []int{99, 199, 749}},
+}
+
+var totalWeight = func() int {
+	total := 0
+	for _, s := range Services {
+		total += s.Weight
+	}
+	return total
+}()
@@ -0,0 +1,87 @@
+package stream
+
+import "time"
+
+// gapWindow is how long an ID skipped over by a later change is waited for.
+// Audit IDs are taken on insert, so a transaction that started first can
+// commit after a change with a higher ID was already published. Changes
+// committed later than this while the listener is down are missed.
+const gapWindow = 5 * time.Minute
+
+// gap is the IDs from from up to a change skipped over when it was
+// published at at
+type gap struct {
+	from int64
+	at   time.Time
+}
+
+// cursor tracks which changes the listener has published, so a catch-up
+// after a reconnect reads the IDs it skipped over as well as the ones after
+// the highest, and publishes each change only once.
+type cursor struct {
+	lastID int64
+	gaps   []gap
+	// seen holds the published IDs above floor, the ID catch-ups start after
+	seen  map[int64]struct{}
+	floor int64
+}
+
+func newCursor() *cursor {
+	return &cursor{seen: make(map[int64]struct{})}
+}
+
+// after is the ID to catch up from, the last change before the oldest gap
+// still waited for. Zero means nothing was published yet.
+func (c *cursor) after(now time.Time) int64 {
+	c.expire(now)
+	return c.floor
+}
+
+// mark records a change as published. It reports false when the change was
+// published before, or belongs to a gap that was given up on.
+func (c *cursor) mark(id int64, now time.Time) bool {
+	if _, ok := c.seen[id]; ok || id <= c.after(now) {
+		return false
+	}
+	c.seen[id] = struct{}{}
+
+	// Before the first change there is nothing to have skipped
+	if c.lastID > 0 && id > c.lastID+1 {
+		c.gaps = append(c.gaps, gap{from: c.lastID + 1, at: now})
+	}
+	c.lastID = max(c.lastID, id)
+	c.raiseFloor()
+	return true
+}
+
+// expire gives up on gaps older than gapWindow. Gaps are opened in order, so
+// the oldest is also the lowest.
+func (c *cursor) expire(now time.Time) {
+	n := 0
+	for n < len(c.gaps) && now.Sub(c.gaps[n].at) >= gapWindow {
+		n++
+	}
+	if n > 0 {
+		c.gaps = c.gaps[n:]
+		c.raiseFloor()
+	}
+}
+
+// raiseFloor moves the floor up to the last change before the oldest gap
+// and forgets the IDs below it, no catch-up reads them again
+func (c *cursor) raiseFloor() {
+	floor := c.lastID
+	if len(c.gaps) > 0 {
+		floor = c.gaps[0].from - 1
+	}
+	if floor == c.floor {
+		return
+	}
+	c.floor = floor
+
+	for id := range c.seen {
+		if id <= floor {
+			delete(c.seen, id)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package stream
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type step struct {
+		// at is the offset from start; mark publishes id, after is read when id is 0
+		at        time.Duration
+		id        int64
+		wantMark  bool
+		wantAfter int64
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "in order",
+			steps: []step{
+				{id: 0, wantAfter: 0},
+				{id: 1, wantMark: true},
+				{id: 2, wantMark: true},
+				{id: 0, wantAfter: 2},
+			},
+		},
+		{
+			name: "duplicates are dropped",
+			steps: []step{
+				{id: 5, wantMark: true},
+				{id: 5},
+				{id: 4},
+			},
+		},
+		{
+			name: "a gap holds the catch-up back",
+			steps: []step{
+				{id: 1, wantMark: true},
+				{id: 3, wantMark: true},
+				{id: 0, wantAfter: 1},
+				// 3 is still remembered, a catch-up from 1 reads it again
+				{id: 3},
+				{id: 2, wantMark: true},
+			},
+		},
+		{
+			name: "a filled gap is still waited for",
+			steps: []step{
+				{id: 1, wantMark: true},
+				{id: 3, wantMark: true},
+				{id: 2, wantMark: true},
+				{id: 0, wantAfter: 1},
+			},
+		},
+		{
+			name: "gaps are given up on after the window",
+			steps: []step{
+				{id: 1, wantMark: true},
+				{id: 3, wantMark: true},
+				{at: gapWindow, id: 0, wantAfter: 3},
+				{at: gapWindow, id: 2},
+				{at: gapWindow, id: 4, wantMark: true},
+			},
+		},
+		{
+			name: "only expired gaps are given up on",
+			steps: []step{
+				{id: 1, wantMark: true},
+				{id: 3, wantMark: true},
+				{at: time.Minute, id: 5, wantMark: true},
+				{at: gapWindow, id: 0, wantAfter: 3},
+				{at: gapWindow, id: 4, wantMark: true},
+				{at: gapWindow + time.Minute, id: 0, wantAfter: 5},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCursor()
+			for i, s := range tt.steps {
+				now := start.Add(s.at)
+				if s.id == 0 {
+					if got := c.after(now); got != s.wantAfter {
+						t.Fatalf("step %d: after = %d, want %d", i, got, s.wantAfter)
+					}
+					continue
+				}
+				if got := c.mark(s.id, now); got != s.wantMark {
+					t.Fatalf("step %d: mark(%d) = %v, want %v", i, s.id, got, s.wantMark)
+				}
+			}
+			for id := range c.seen {
+				if id <= c.floor {
+					t.Errorf("seen keeps %d below the floor %d", id, c.floor)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+// Package stream fans committed subscription changes out to live clients.
+// Every app instance listens on the same PostgreSQL channel, so a change made
+// through one instance reaches clients connected to any of them.
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/nurkenspashev92/emob/internal/models"
+	"github.com/nurkenspashev92/emob/internal/repositories"
+)
+
+// Channel is notified by the trigger from migration 000009
+const Channel = "subscription_changes"
+
+// clientBuffer is how many changes a client may fall behind before it is
+// disconnected. It then resumes with Last-Event-ID.
+const clientBuffer = 64
+
+// catchUpPage is how many missed changes are read at a time after the
+// listener reconnects
+const catchUpPage = 1000
+
+// Client is one open stream
+type Client struct {
+	filter  models.SubscriptionChangeFilter
+	changes chan models.SubscriptionChange
+}
+
+// Changes is closed when the client is dropped for being too slow or the hub
+// shuts down.
+func (c *Client) Changes() <-chan models.SubscriptionChange {
+	return c.changes
+}
+
+type Hub struct {
+	db   *pgxpool.Pool
+	repo *repositories.AuditRepository
+
+	mu      sync.Mutex
+	clients map[*Client]struct{}
+	closed  bool
+
+	listening atomic.Bool
+}
+
+func NewHub(db *pgxpool.Pool) *Hub {
+	return &Hub{
+		db:      db,
+		repo:    repositories.NewAuditRepository(db),
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(filter models.SubscriptionChangeFilter) *Client {
+	c := &Client{filter: filter, changes: make(chan models.SubscriptionChange, clientBuffer)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(c.changes)
+		return c
+	}
+	h.clients[c] = struct{}{}
+	return c
+}
+
+func (h *Hub) Unsubscribe(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.clients[c]; ok {
+		delete(h.clients, c)
+		close(c.changes)
+	}
+}
+
+// Close ends every open stream so the HTTP server can shut down without
+// waiting for them.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.changes)
+	}
+}
+
+func (h *Hub) broadcast(change models.SubscriptionChange) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		if !c.filter.Match(change) {
+			continue
+		}
+
+		select {
+		case c.changes <- change:
+		default:
+			delete(h.clients, c)
+			close(c.changes)
+		}
+	}
+}
+
+// Run listens for notifications until ctx is cancelled, reconnecting with a
+// growing delay when the connection is lost.
+func (h *Hub) Run(ctx context.Context) {
+	published := newCursor()
+	delay := time.Second
+
+	for {
+		err := h.listen(ctx, published, func() {
+			delay = time.Second
+			h.listening.Store(true)
+		})
+		h.listening.Store(false)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("Change stream listener failed", "retry_in", delay, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, 30*time.Second)
+	}
+}
+
+// listen holds a dedicated connection outside the pool, since a LISTEN
+// session must not be handed to other queries.
+func (h *Hub) listen(ctx context.Context, published *cursor, connected func()) error {
+	conn, err := pgx.ConnectConfig(ctx, h.db.Config().ConnConfig.Copy())
+	if err != nil {
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	connected()
+
+	// Catch up on changes committed while the listener was down, including
+	// ones below the last published ID that committed late
+	if after := published.after(time.Now()); after > 0 {
+		for {
+			missed, err := h.repo.GetSubscriptionChangesAfter(ctx, after, catchUpPage)
+			if err != nil {
+				return err
+			}
+			for _, change := range missed {
+				after = change.ID
+				h.publish(change, published)
+			}
+			if len(missed) < catchUpPage {
+				break
+			}
+		}
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		id, err := strconv.ParseInt(n.Payload, 10, 64)
+		if err != nil {
+			slog.Warn("Change stream ignored notification", "payload", n.Payload)
+			continue
+		}
+
+		change, err := h.repo.GetSubscriptionChange(ctx, id)
+		if err != nil {
+			return err
+		}
+		h.publish(*change, published)
+	}
+}
+
+// Check reports whether the listener is connected. Clients of an instance
+// whose listener is down receive no changes until it reconnects.
+func (h *Hub) Check(context.Context) error {
+	if !h.listening.Load() {
+		return errors.New("change stream listener is not connected")
+	}
+	return nil
+}
+
+// publish broadcasts a change unless it was already, a catch-up reads
+// changes that may have been notified too
+func (h *Hub) publish(change models.SubscriptionChange, published *cursor) {
+	if published.mark(change.ID, time.Now()) {
+		h.broadcast(change)
+	}
+}
@@ -0,0 +1,160 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nurkenspashev92/emob/configs"
+)
+
+var clientAuth = map[string]tls.ClientAuthType{
+	"none":            tls.NoClientCert,
+	"request":         tls.RequestClientCert,
+	"verify_if_given": tls.VerifyClientCertIfGiven,
+	"require":         tls.RequireAndVerifyClientCert,
+}
+
+var minVersion = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Reloader serves the certificate and client CAs read from files, and
+// reads them again when the files change. A renewal that fails to load
+// keeps the previous certificate in use.
+type Reloader struct {
+	cfg configs.TLSConfig
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	clients *x509.CertPool
+	stamp   string
+}
+
+func NewReloader(cfg configs.TLSConfig) (*Reloader, error) {
+	r := &Reloader{cfg: cfg}
+	if _, err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Config returns the server TLS configuration. Every handshake sees the
+// certificate and client CAs loaded last.
+func (r *Reloader) Config() *tls.Config {
+	version := minVersion[r.cfg.MinVersion]
+	auth := clientAuth[r.cfg.ClientAuth]
+
+	return &tls.Config{
+		MinVersion: version,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mu.RLock()
+			defer r.mu.RUnlock()
+
+			return &tls.Config{
+				MinVersion:   version,
+				ClientAuth:   auth,
+				ClientCAs:    r.clients,
+				Certificates: []tls.Certificate{*r.cert},
+			}, nil
+		},
+	}
+}
+
+// Run checks the files on every tick until ctx is cancelled
+func (r *Reloader) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.ReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		reloaded, err := r.reload()
+		if err != nil {
+			slog.Error("Failed to reload TLS certificate, keeping the previous one", "error", err)
+			continue
+		}
+		if reloaded {
+			slog.Info("Reloaded TLS certificate", "cert_file", r.cfg.CertFile, "expires", r.expiry())
+		}
+	}
+}
+
+// reload reads the files again if any of them changed since the last
+// successful load
+func (r *Reloader) reload() (bool, error) {
+	stamp, err := r.fileStamp()
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	unchanged := stamp == r.stamp
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.cfg.CertFile, r.cfg.KeyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load TLS key pair: %w", err)
+	}
+
+	var clients *x509.CertPool
+	if r.cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.cfg.ClientCAFile)
+		if err != nil {
+			return false, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clients = x509.NewCertPool()
+		if !clients.AppendCertsFromPEM(pem) {
+			return false, errors.New("client CA file contains no PEM certificates")
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clients = clients
+	r.stamp = stamp
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+// fileStamp changes whenever one of the files is replaced or rewritten.
+// Stat follows symlinks, so mounted secrets swapped by a symlink count too.
+func (r *Reloader) fileStamp() (string, error) {
+	var stamp string
+	for _, name := range []string{r.cfg.CertFile, r.cfg.KeyFile, r.cfg.ClientCAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat TLS file: %w", err)
+		}
+		stamp += fmt.Sprintf("%s:%d:%d;", name, info.ModTime().UnixNano(), info.Size())
+	}
+	return stamp, nil
+}
+
+func (r *Reloader) expiry() time.Time {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.cert.Leaf == nil {
+		return time.Time{}
+	}
+	return r.cert.Leaf.NotAfter
+}
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// QueryTracer creates a client span for every query and batch sent through
+// a pgx connection. Set it as ConnConfig.Tracer.
+type QueryTracer struct{}
+
+func NewQueryTracer() *QueryTracer {
+	return &QueryTracer{}
+}
+
+func (t *QueryTracer) TraceQueryStart(
+	ctx context.Context,
+	conn *pgx.Conn,
+	data pgx.TraceQueryStartData,
+) context.Context {
+
+	ctx, _ = startSpan(ctx, conn, operationName(data.SQL),
+		semconv.DBOperationName(operationName(data.SQL)),
+		semconv.DBQueryText(data.SQL),
+	)
+	return ctx
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int64("db.response.returned_rows", data.CommandTag.RowsAffected()))
+	endSpan(span, data.Err)
+}
+
+func (t *QueryTracer) TraceBatchStart(
+	ctx context.Context,
+	conn *pgx.Conn,
+	data pgx.TraceBatchStartData,
+) context.Context {
+
+	ctx, _ = startSpan(ctx, conn, "BATCH",
+		semconv.DBOperationName("BATCH"),
+		semconv.DBOperationBatchSize(data.Batch.Len()),
+	)
+	return ctx
+}
+
+// TraceBatchQuery records each statement of a batch as an event, since they
+// share one round trip.
+func (t *QueryTracer) TraceBatchQuery(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchQueryData) {
+	attrs := []attribute.KeyValue{semconv.DBQueryText(data.SQL)}
+	if data.Err != nil {
+		attrs = append(attrs, attribute.String("error", data.Err.Error()))
+	}
+	trace.SpanFromContext(ctx).AddEvent("query", trace.WithAttributes(attrs...))
+}
+
+func (t *QueryTracer) TraceBatchEnd(ctx context.Context, _ *pgx.Conn, data pgx.TraceBatchEndData) {
+	endSpan(trace.SpanFromContext(ctx), data.Err)
+}
+
+func startSpan(
+	ctx context.Context,
+	conn *pgx.Conn,
+	name string,
+	attrs ...attribute.KeyValue,
+) (context.Context, trace.Span) {
+
+	cfg := conn.Config()
+	attrs = append(attrs,
+		semconv.DBSystemNamePostgreSQL,
+		semconv.DBNamespace(cfg.Database),
+		semconv.ServerAddress(cfg.Host),
+		semconv.ServerPort(int(cfg.Port)),
+	)
+
+	return otel.Tracer(Name).Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+}
+
+// endSpan marks the span failed, except for queries that found no rows which
+// callers treat as a regular outcome.
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// operationName is the first keyword of the statement, such as SELECT
+func operationName(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "QUERY"
+	}
+	return strings.ToUpper(fields[0])
+}
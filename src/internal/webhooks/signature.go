@@ -0,0 +1,98 @@
+// Package webhooks holds the wire format shared by the delivery worker and
+// receivers: request headers and the HMAC-SHA256 signature scheme.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "t=<unix seconds>,v1=<hex hmac>"
+	SignatureHeader = "X-Emob-Signature"
+	EventHeader     = "X-Emob-Event"
+	DeliveryHeader  = "X-Emob-Delivery"
+)
+
+var (
+	ErrMalformedSignature = errors.New("malformed signature header")
+	ErrSignatureMismatch  = errors.New("signature does not match")
+	ErrSignatureExpired   = errors.New("signature timestamp outside tolerance")
+)
+
+// GenerateSecret returns a random signing secret
+func GenerateSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return "whsec_" + base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Sign builds the signature header value. The timestamp is part of the signed
+// content so a captured request cannot be replayed later.
+func Sign(secret string, ts time.Time, body []byte) string {
+	unix := ts.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", unix, mac(secret, unix, body))
+}
+
+// Verify checks a signature header against the body. A zero tolerance skips
+// the timestamp check.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var (
+		unix int64
+		sigs []string
+	)
+
+	for _, part := range strings.Split(header, ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return ErrMalformedSignature
+		}
+
+		switch k {
+		case "t":
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return ErrMalformedSignature
+			}
+			unix = n
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+
+	if unix == 0 || len(sigs) == 0 {
+		return ErrMalformedSignature
+	}
+
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(unix, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	expected := mac(secret, unix, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrSignatureMismatch
+}
+
+func mac(secret string, unix int64, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", unix)
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
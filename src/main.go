@@ -5,9 +5,12 @@
 // @BasePath /api/v1
 package main
 
-import "github.com/nurkenspashev92/emob/cmd/app"
+import (
+	"os"
+
+	"github.com/nurkenspashev92/emob/cmd/cli"
+)
 
 func main() {
-	app := new(app.App)
-	app.Run()
+	cli.Run(os.Args[1:])
 }
@@ -0,0 +1,27 @@
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    entity_type VARCHAR(64) NOT NULL,
+    entity_id UUID NOT NULL,
+    action VARCHAR(32) NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    before JSONB,
+    after JSONB,
+    diff JSONB NOT NULL DEFAULT '{}'::jsonb,
+    request_id VARCHAR(128),
+    ip VARCHAR(64),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_audit_events_entity ON audit_events (entity_type, entity_id, created_at DESC);
+CREATE INDEX idx_audit_events_actor ON audit_events (actor, created_at DESC);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at DESC);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_events_append_only
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,5 @@
+CREATE TABLE calendar_feed_tokens (
+    user_id UUID PRIMARY KEY,
+    token_hash CHAR(64) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
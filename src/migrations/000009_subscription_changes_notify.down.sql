@@ -0,0 +1,2 @@
+DROP TRIGGER IF EXISTS trg_audit_events_notify_subscription_change ON audit_events;
+DROP FUNCTION IF EXISTS notify_subscription_change();
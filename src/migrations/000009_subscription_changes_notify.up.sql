@@ -0,0 +1,15 @@
+-- Every subscription mutation writes an audit event in the same transaction,
+-- so notifying on those inserts fires once per committed change. The payload
+-- is only the event id; listeners read the event itself from audit_events.
+CREATE FUNCTION notify_subscription_change() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('subscription_changes', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_audit_events_notify_subscription_change
+    AFTER INSERT ON audit_events
+    FOR EACH ROW
+    WHEN (NEW.entity_type = 'subscription' AND NEW.action IN ('create', 'update', 'delete', 'restore'))
+    EXECUTE FUNCTION notify_subscription_change();
@@ -2,43 +2,118 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
+	"strconv"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/internal/tracing"
 )
 
 type Database struct {
 	Conn *pgxpool.Pool
 }
 
-var dbInstance *Database
-
-func NewPostgresDb(conf *configs.Config) (*Database, error) {
-	if dbInstance != nil {
-		return dbInstance, nil
+// NewPostgresDb opens a pool and waits for the database to accept
+// connections, retrying with backoff for up to DB_CONNECT_RETRY_TIMEOUT so
+// the app can start alongside a database that is still booting. Every call
+// returns its own pool.
+func NewPostgresDb(ctx context.Context, conf *configs.Config) (*Database, error) {
+	poolConfig, err := newPoolConfig(conf)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	conn, err := pgxpool.New(ctx, conf.DatabaseURL())
+	conn, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect db: %w", err)
 	}
 
-	if err := conn.Ping(ctx); err != nil {
+	if err := waitForDatabase(ctx, conn.Ping, conf.Pool); err != nil {
 		conn.Close()
 		return nil, fmt.Errorf("failed to ping db: %w", err)
 	}
 
-	dbInstance = &Database{
+	return &Database{
 		Conn: conn,
+	}, nil
+}
+
+// newPoolConfig builds the pgxpool settings from the app configuration
+func newPoolConfig(conf *configs.Config) (*pgxpool.Config, error) {
+	poolConfig, err := pgxpool.ParseConfig(conf.DatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse db config: %w", err)
 	}
 
-	return dbInstance, nil
+	pool := conf.Pool
+	poolConfig.MaxConns = int32(pool.MaxConns)
+	poolConfig.MinConns = int32(pool.MinConns)
+	poolConfig.MaxConnLifetime = pool.MaxConnLifetime
+	poolConfig.MaxConnLifetimeJitter = pool.MaxConnLifetimeJitter
+	poolConfig.MaxConnIdleTime = pool.MaxConnIdleTime
+	poolConfig.HealthCheckPeriod = pool.HealthCheckPeriod
+	poolConfig.ConnConfig.ConnectTimeout = pool.ConnectTimeout
+
+	// The server enforces this even when a caller sets no deadline
+	if pool.StatementTimeout > 0 {
+		poolConfig.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(pool.StatementTimeout.Milliseconds(), 10)
+	}
+
+	// Every query becomes a span under the request that issued it
+	poolConfig.ConnConfig.Tracer = tracing.NewQueryTracer()
+
+	return poolConfig, nil
+}
+
+func waitForDatabase(ctx context.Context, ping func(context.Context) error, cfg configs.PoolConfig) error {
+	deadline := time.Now().Add(cfg.ConnectRetryTimeout)
+	delay := cfg.ConnectBackoffBase
+
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+		err := ping(pingCtx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if !retryable(err) || time.Now().Add(delay).After(deadline) {
+			return err
+		}
+
+		slog.Warn("Database is not available yet, retrying", "attempt", attempt, "retry_in", delay.String(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay = min(delay*2, cfg.ConnectBackoffMax)
+	}
+}
+
+// retryable reports whether a failed connection attempt may succeed later.
+// Anything the server answers with, such as a wrong password or a missing
+// database, is final except for the errors sent while it starts up or is
+// out of connection slots.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return true
+	}
+
+	switch pgErr.Code {
+	case "57P03", "53300": // cannot_connect_now, too_many_connections
+		return true
+	}
+	return false
 }
 
 func (db *Database) Close() {
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratepgx "github.com/golang-migrate/migrate/v4/database/pgx/v5"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/nurkenspashev92/emob/configs"
+	"github.com/nurkenspashev92/emob/migrations"
+)
+
+// Migrator applies the migrations embedded in the binary. Versions are kept
+// in the schema_migrations table of golang-migrate, so databases migrated by
+// its CLI carry on where they left off. Every change runs under a Postgres
+// advisory lock, instances migrating at the same time wait for each other.
+type Migrator struct {
+	db *sql.DB
+	m  *migrate.Migrate
+}
+
+// MigrationStatus is one migration and whether the schema includes it
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(.+)\.up\.sql$`)
+
+// NewMigrator opens a dedicated connection, waiting for the database like
+// NewPostgresDb does. It skips DB_STATEMENT_TIMEOUT since schema changes on
+// large tables may run longer than any query.
+func NewMigrator(ctx context.Context, conf *configs.Config) (*Migrator, error) {
+	connConfig, err := pgx.ParseConfig(conf.DatabaseURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse db config: %w", err)
+	}
+	connConfig.ConnectTimeout = conf.Pool.ConnectTimeout
+
+	db := stdlib.OpenDB(*connConfig)
+	if err := waitForDatabase(ctx, db.PingContext, conf.Pool); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping db: %w", err)
+	}
+
+	driver, err := migratepgx.WithInstance(db, &migratepgx.Config{})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open migration driver: %w", err)
+	}
+
+	source, err := iofs.New(migrations.FS, ".")
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "pgx5", driver)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to init migrations: %w", err)
+	}
+	m.LockTimeout = conf.Migrate.LockTimeout
+	m.Log = migrateLogger{}
+
+	return &Migrator{db: db, m: m}, nil
+}
+
+// Up applies all pending migrations
+func (mg *Migrator) Up() error {
+	return ignoreNoChange(mg.m.Up())
+}
+
+// Steps applies n pending migrations, or rolls back -n applied ones
+func (mg *Migrator) Steps(n int) error {
+	return ignoreNoChange(mg.m.Steps(n))
+}
+
+// Force records version as applied and clears the dirty flag without
+// running anything. It is how a failed migration is marked as dealt with.
+func (mg *Migrator) Force(version int) error {
+	return mg.m.Force(version)
+}
+
+// Version returns the schema version, 0 when no migration was applied
+func (mg *Migrator) Version() (uint, bool, error) {
+	version, dirty, err := mg.m.Version()
+	if errors.Is(err, migrate.ErrNilVersion) {
+		return 0, false, nil
+	}
+	return version, dirty, err
+}
+
+// Status lists the embedded migrations in order
+func (mg *Migrator) Status() ([]MigrationStatus, error) {
+	version, _, err := mg.Version()
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := embeddedMigrations()
+	if err != nil {
+		return nil, err
+	}
+	for i := range list {
+		list[i].Applied = list[i].Version <= version
+	}
+	return list, nil
+}
+
+func (mg *Migrator) Close() error {
+	srcErr, dbErr := mg.m.Close()
+	return errors.Join(srcErr, dbErr, mg.db.Close())
+}
+
+// CheckSchema fails when the schema is behind the newest embedded migration
+// or a migration was left half applied. A newer schema is accepted so an
+// older release can still run against it during a rollback.
+func CheckSchema(ctx context.Context, db *pgxpool.Pool) error {
+	expected, err := LatestMigration()
+	if err != nil {
+		return err
+	}
+
+	var (
+		version int64
+		dirty   bool
+	)
+	err = db.QueryRow(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("no migrations applied")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	switch {
+	case dirty:
+		return fmt.Errorf("migration %d is dirty", version)
+	case uint(version) < expected:
+		return fmt.Errorf("schema is at version %d, expected %d", version, expected)
+	}
+	return nil
+}
+
+// LatestMigration returns the newest embedded migration version
+func LatestMigration() (uint, error) {
+	list, err := embeddedMigrations()
+	if err != nil {
+		return 0, err
+	}
+	if len(list) == 0 {
+		return 0, nil
+	}
+	return list[len(list)-1].Version, nil
+}
+
+func embeddedMigrations() ([]MigrationStatus, error) {
+	entries, err := fs.ReadDir(migrations.FS, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	var list []MigrationStatus
+	for _, e := range entries {
+		m := migrationFile.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		v, err := strconv.ParseUint(m[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		list = append(list, MigrationStatus{Version: uint(v), Name: m[2]})
+	}
+
+	slices.SortFunc(list, func(a, b MigrationStatus) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	return list, nil
+}
+
+func ignoreNoChange(err error) error {
+	if errors.Is(err, migrate.ErrNoChange) {
+		return nil
+	}
+	return err
+}
+
+// migrateLogger reports each applied migration through slog
+type migrateLogger struct{}
+
+func (migrateLogger) Printf(format string, v ...any) {
+	slog.Info(strings.TrimSpace(fmt.Sprintf(format, v...)), "component", "migrate")
+}
+
+func (migrateLogger) Verbose() bool {
+	return false
+}